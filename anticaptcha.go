@@ -1,25 +1,22 @@
 package anticaptcha
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
 	"net/http"
 	"strconv"
-
-	"github.com/packman80/anticaptcha/internal"
 )
 
 type AntiCaptcha struct {
+	name    string
 	baseUrl string
 	apiKey  string
 }
 
 func NewAntiCaptcha(apiKey string) *AntiCaptcha {
 	return &AntiCaptcha{
+		name:    "anticaptcha",
 		apiKey:  apiKey,
 		baseUrl: "https://api.anti-captcha.com",
 	}
@@ -27,6 +24,7 @@ func NewAntiCaptcha(apiKey string) *AntiCaptcha {
 
 func NewCapMonsterCloud(apiKey string) *AntiCaptcha {
 	return &AntiCaptcha{
+		name:    "capmonster",
 		apiKey:  apiKey,
 		baseUrl: "https://api.capmonster.cloud",
 	}
@@ -36,65 +34,69 @@ func NewCapMonsterCloud(apiKey string) *AntiCaptcha {
 // have the exact same API as AntiCaptcha, thus allowing you to use these providers with ease.
 func NewCustomAntiCaptcha(baseUrl, apiKey string) *AntiCaptcha {
 	return &AntiCaptcha{
+		name:    nameFromBaseUrl(baseUrl, "anticaptcha"),
 		baseUrl: baseUrl,
 		apiKey:  apiKey,
 	}
 }
 
+// Name returns the name of the provider as used in errors, e.g. "anticaptcha" or the host of a custom baseUrl.
+func (a *AntiCaptcha) Name() string {
+	return a.name
+}
+
 func (a *AntiCaptcha) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
-	task := map[string]any{
-		"type": "ImageToTextTask",
-		"body": payload.Base64String,
-		"case": payload.CaseSensitive,
-	}
+	return a.solve(ctx, settings, payload)
+}
 
-	result, err := a.solveTask(ctx, settings, task)
-	if err != nil {
-		return nil, err
-	}
+func (a *AntiCaptcha) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	return a.solve(ctx, settings, payload)
+}
 
-	return result, nil
+func (a *AntiCaptcha) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	return a.solve(ctx, settings, payload)
 }
 
-func (a *AntiCaptcha) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
-	task := map[string]any{
-		"type":        "NoCaptchaTaskProxyless",
-		"websiteURL":  payload.EndpointUrl,
-		"websiteKey":  payload.EndpointKey,
-		"isInvisible": payload.IsInvisibleCaptcha}
+func (a *AntiCaptcha) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	return a.solve(ctx, settings, payload)
+}
 
-	result, err := a.solveTask(ctx, settings, task)
-	if err != nil {
-		return nil, err
-	}
+func (a *AntiCaptcha) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	return a.solve(ctx, settings, payload)
+}
 
-	return result, nil
+func (a *AntiCaptcha) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return a.solve(ctx, settings, payload)
 }
 
-func (a *AntiCaptcha) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
-	task := map[string]any{
-		"type":       "RecaptchaV3TaskProxyless",
-		"websiteURL": payload.EndpointUrl,
-		"websiteKey": payload.EndpointKey,
-		"minScore":   payload.MinScore,
-	}
+func (a *AntiCaptcha) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return a.solve(ctx, settings, payload)
+}
 
-	result, err := a.solveTask(ctx, settings, task)
+// CreateTask submits the payload without waiting for the solution, see CaptchaSolver.Submit
+func (a *AntiCaptcha) CreateTask(ctx context.Context, settings *Settings, payload any) (*TaskHandle, error) {
+	captchaType, task, err := a.buildTask(payload)
 	if err != nil {
+		settings.observeRefused(a.name, captchaType, err)
 		return nil, err
 	}
 
-	return result, nil
+	return a.submit(ctx, settings, captchaType, task)
 }
 
-func (a *AntiCaptcha) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
-	task := map[string]any{
-		"type":       "HCaptchaTaskProxyless",
-		"websiteURL": payload.EndpointUrl,
-		"websiteKey": payload.EndpointKey,
+// GetResult fetches the result of the task once, see CaptchaSolver.Poll
+func (a *AntiCaptcha) GetResult(ctx context.Context, settings *Settings, handle *TaskHandle) (ICaptchaResponse, error) {
+	return fetchResult(ctx, settings, a, handle, a.getResult)
+}
+
+func (a *AntiCaptcha) solve(ctx context.Context, settings *Settings, payload any) (ICaptchaResponse, error) {
+	captchaType, task, err := a.buildTask(payload)
+	if err != nil {
+		settings.observeRefused(a.name, captchaType, err)
+		return nil, err
 	}
 
-	result, err := a.solveTask(ctx, settings, task)
+	result, err := a.solveTask(ctx, settings, captchaType, task)
 	if err != nil {
 		return nil, err
 	}
@@ -102,102 +104,145 @@ func (a *AntiCaptcha) SolveHCaptcha(ctx context.Context, settings *Settings, pay
 	return result, nil
 }
 
-func (a *AntiCaptcha) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
-	task := map[string]any{
-		"type":       "TurnstileTaskProxyless",
-		"websiteURL": payload.EndpointUrl,
-		"websiteKey": payload.EndpointKey,
+// buildTask converts a payload into an AntiCaptcha task.
+func (a *AntiCaptcha) buildTask(payload any) (CaptchaType, map[string]any, error) {
+	captchaType, err := captchaTypeOf(a.name, payload)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var task map[string]any
+	switch payload := payload.(type) {
+	case *ImageCaptchaPayload:
+		task = map[string]any{
+			"type": "ImageToTextTask",
+			"body": payload.Base64String,
+			"case": payload.CaseSensitive,
+		}
+	case *RecaptchaV2Payload:
+		task = map[string]any{
+			"type":        "NoCaptchaTaskProxyless",
+			"websiteURL":  payload.EndpointUrl,
+			"websiteKey":  payload.EndpointKey,
+			"isInvisible": payload.IsInvisibleCaptcha,
+		}
+		err = setAntiCaptchaProxy(task, "NoCaptchaTask", payload.Proxy)
+	case *RecaptchaV3Payload:
+		task = map[string]any{
+			"type":       "RecaptchaV3TaskProxyless",
+			"websiteURL": payload.EndpointUrl,
+			"websiteKey": payload.EndpointKey,
+			"minScore":   payload.MinScore,
+		}
+
+		// AntiCaptcha compatible APIs only solve reCAPTCHA v3 without a proxy
+		if payload.Proxy != nil {
+			return captchaType, nil, &ProviderError{
+				Provider:    a.name,
+				CaptchaType: captchaType,
+				Description: "recaptcha v3 with a proxy is not supported",
+				Err:         ErrUnsupported,
+			}
+		}
+	case *HCaptchaPayload:
+		task = map[string]any{
+			"type":       "HCaptchaTaskProxyless",
+			"websiteURL": payload.EndpointUrl,
+			"websiteKey": payload.EndpointKey,
+		}
+		err = setAntiCaptchaProxy(task, "HCaptchaTask", payload.Proxy)
+	case *TurnstilePayload:
+		task = map[string]any{
+			"type":       "TurnstileTaskProxyless",
+			"websiteURL": payload.EndpointUrl,
+			"websiteKey": payload.EndpointKey,
+		}
+		err = setAntiCaptchaProxy(task, "TurnstileTask", payload.Proxy)
+	case *CoordinatesPayload:
+		/*
+			task = map[string]any{
+				"type":            "ImageToCoordinatesTask",
+				"body":            payload.Body,
+				"imginstructions": payload.ImageInstructions,
+			}
+		*/
+		return captchaType, nil, unsupportedError(a.name, captchaType)
+	case *CustomPayload:
+		task = payload.Params
 	}
 
-	result, err := a.solveTask(ctx, settings, task)
 	if err != nil {
-		return nil, err
+		return captchaType, nil, proxyError(a.name, captchaType, err)
 	}
 
-	return result, nil
+	return captchaType, task, nil
 }
 
-func (a *AntiCaptcha) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
-	/*
-		task := map[string]any{
-			"type":            "ImageToCoordinatesTask",
-			"body":            payload.Body,
-			"imginstructions": payload.ImageInstructions,
-		}
+// setAntiCaptchaProxy switches the task to its proxy variant and adds the proxy fields, it does nothing when proxy is nil.
+func setAntiCaptchaProxy(task map[string]any, proxyTaskType string, proxy *Proxy) error {
+	if proxy == nil {
+		return nil
+	}
 
-		result, err := a.solveTask(ctx, settings, task)
-		if err != nil {
-			return nil, err
-		}
+	if err := proxy.validate(); err != nil {
+		return err
+	}
 
-	*/
-	return nil, fmt.Errorf("Coordinates Captcha doesn't support")
+	task["type"] = proxyTaskType
+	task["proxyType"] = string(proxy.proxyType())
+	task["proxyAddress"] = proxy.Address
+	task["proxyPort"] = proxy.Port
+	if proxy.Login != "" {
+		task["proxyLogin"] = proxy.Login
+		task["proxyPassword"] = proxy.Password
+	}
+
+	return nil
 }
 
-func (a *AntiCaptcha) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
-	result, err := a.solveTask(ctx, settings, payload.Params)
+func (a *AntiCaptcha) solveTask(ctx context.Context, settings *Settings, captchaType CaptchaType, task map[string]any) (*CaptchaResponse, error) {
+	handle, err := a.submit(ctx, settings, captchaType, task)
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	return pollResult(ctx, settings, a, handle, a.getResult)
 }
 
-func (a *AntiCaptcha) solveTask(ctx context.Context, settings *Settings, task map[string]any) (*CaptchaResponse, error) {
-	taskId, err := a.createTask(ctx, settings, task)
+// submit creates the task, asking for a callback when the settings have a callback receiver.
+func (a *AntiCaptcha) submit(ctx context.Context, settings *Settings, captchaType CaptchaType, task map[string]any) (*TaskHandle, error) {
+	handle, err := submitTask(settings, a.name, captchaType, func() (string, error) {
+		return a.createTask(ctx, settings, captchaType, task)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if err := internal.SleepWithContext(ctx, settings.initialWaitTime); err != nil {
-		return nil, err
-	}
-
-	for i := 0; i < settings.maxRetries; i++ {
-		answer, err := a.getResult(ctx, settings, taskId)
-		if err != nil {
-			return nil, err
-		}
-
-		if answer != "" {
-			return &CaptchaResponse{solution: answer, taskId: taskId}, nil
-		}
-
-		if err := internal.SleepWithContext(ctx, settings.pollInterval); err != nil {
-			return nil, err
-		}
-	}
-
-	return nil, errors.New("max tries exceeded")
+	handle.callback = settings.callback != nil
+	return handle, nil
 }
 
-func (a *AntiCaptcha) createTask(ctx context.Context, settings *Settings, task map[string]any) (string, error) {
+func (a *AntiCaptcha) createTask(ctx context.Context, settings *Settings, captchaType CaptchaType, task map[string]any) (string, error) {
 	type antiCaptchaCreateResponse struct {
 		ErrorID          int    `json:"errorId"`
+		ErrorCode        string `json:"errorCode"`
 		ErrorDescription string `json:"errorDescription"`
 		TaskID           any    `json:"taskId"`
 	}
 
-	jsonValue, err := json.Marshal(map[string]any{"clientKey": a.apiKey, "task": task})
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseUrl+"/createTask", bytes.NewBuffer(jsonValue))
-	if err != nil {
-		return "", err
+	request := map[string]any{"clientKey": a.apiKey, "task": task}
+	if callbackURL := settings.callbackURL(a.name); callbackURL != "" {
+		request["callbackUrl"] = callbackURL
 	}
-	req.Header.Set("content-type", "application/json")
 
-	resp, err := settings.client.Do(req)
+	jsonValue, err := json.Marshal(request)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := doRequest(ctx, settings, a.name, requestCreate, http.MethodPost, a.baseUrl+"/createTask", "application/json", jsonValue)
 	if err != nil {
-		return "", err
+		return "", taskError(err, captchaType, "")
 	}
 
 	var responseAsJSON antiCaptchaCreateResponse
@@ -206,7 +251,7 @@ func (a *AntiCaptcha) createTask(ctx context.Context, settings *Settings, task m
 	}
 
 	if responseAsJSON.ErrorID != 0 {
-		return "", errors.New(responseAsJSON.ErrorDescription)
+		return "", newProviderError(antiCaptchaErrors, a.name, captchaType, "", responseAsJSON.ErrorCode, responseAsJSON.ErrorDescription)
 	}
 
 	switch responseAsJSON.TaskID.(type) {
@@ -222,63 +267,81 @@ func (a *AntiCaptcha) createTask(ctx context.Context, settings *Settings, task m
 	return "", errors.New("unexpected taskId type, expecting string or float64")
 }
 
-func (a *AntiCaptcha) getResult(ctx context.Context, settings *Settings, taskId string) (string, error) {
+func (a *AntiCaptcha) getResult(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) (*CaptchaResponse, error) {
 	type antiCapSolution struct {
-		RecaptchaResponse string `json:"gRecaptchaResponse"`
-		Text              string `json:"text"`
+		RecaptchaResponse string            `json:"gRecaptchaResponse"`
+		Text              string            `json:"text"`
+		Token             string            `json:"token"`
+		UserAgent         string            `json:"userAgent"`
+		Cookies           map[string]string `json:"cookies"`
 	}
 
 	type resultResponse struct {
 		Status           string          `json:"status"`
 		ErrorID          int             `json:"errorId"`
+		ErrorCode        string          `json:"errorCode"`
 		ErrorDescription string          `json:"errorDescription"`
-		Solution         antiCapSolution `json:"solution"`
+		Solution         json.RawMessage `json:"solution"`
+		Cost             any             `json:"cost"`
+		IP               string          `json:"ip"`
+		CreateTime       int64           `json:"createTime"`
+		EndTime          int64           `json:"endTime"`
+		SolveCount       int             `json:"solveCount"`
 	}
 
 	resultData := map[string]string{"clientKey": a.apiKey, "taskId": taskId}
 	jsonValue, err := json.Marshal(resultData)
 	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseUrl+"/getTaskResult", bytes.NewBuffer(jsonValue))
-	if err != nil {
-		return "", err
-	}
-
-	resp, err := settings.client.Do(req)
-	if err != nil {
-		return "", nil
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := doRequest(ctx, settings, a.name, requestPoll, http.MethodPost, a.baseUrl+"/getTaskResult", "application/json", jsonValue)
 	if err != nil {
-		return "", err
+		return nil, taskError(err, captchaType, taskId)
 	}
 
 	var respJson resultResponse
 	if err := json.Unmarshal(respBody, &respJson); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if respJson.ErrorID != 0 {
-		return "", errors.New(respJson.ErrorDescription)
+		return nil, newProviderError(antiCaptchaErrors, a.name, captchaType, taskId, respJson.ErrorCode, respJson.ErrorDescription)
 	}
 
 	if respJson.Status != "ready" {
-		return "", nil
+		return nil, nil
+	}
+
+	var solution antiCapSolution
+	if err := json.Unmarshal(respJson.Solution, &solution); err != nil {
+		return nil, err
 	}
 
-	if respJson.Solution.Text != "" {
-		return respJson.Solution.Text, nil
+	result := &CaptchaResponse{
+		cost:        parseFloat(respJson.Cost),
+		submittedAt: unixTime(respJson.CreateTime),
+		solvedAt:    unixTime(respJson.EndTime),
+		solveCount:  respJson.SolveCount,
+		ip:          respJson.IP,
+		userAgent:   solution.UserAgent,
+		cookies:     solution.Cookies,
+		raw:         respJson.Solution,
 	}
 
-	if respJson.Solution.RecaptchaResponse != "" {
-		return respJson.Solution.RecaptchaResponse, nil
+	switch {
+	case solution.Text != "":
+		result.solution = solution.Text
+	case solution.RecaptchaResponse != "":
+		result.solution = solution.RecaptchaResponse
+	case solution.Token != "":
+		result.solution = solution.Token
+	default:
+		// custom tasks have solutions of their own shape, so the raw JSON is the answer
+		result.solution = string(respJson.Solution)
 	}
 
-	return "", nil
+	return result, nil
 }
 
 func (a *AntiCaptcha) Report(path, taskId string, settings *Settings) func(ctx context.Context) error {
@@ -298,18 +361,7 @@ func (a *AntiCaptcha) Report(path, taskId string, settings *Settings) func(ctx c
 			return err
 		}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseUrl+path, bytes.NewBuffer(rawPayload))
-		if err != nil {
-			return err
-		}
-
-		resp, err := settings.client.Do(req)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-
-		respBody, err := io.ReadAll(resp.Body)
+		respBody, err := doRequest(ctx, settings, a.name, requestPoll, http.MethodPost, a.baseUrl+path, "application/json", rawPayload)
 		if err != nil {
 			return err
 		}
@@ -320,11 +372,72 @@ func (a *AntiCaptcha) Report(path, taskId string, settings *Settings) func(ctx c
 		}
 
 		if respJson.ErrorID != 0 {
-			return fmt.Errorf("%v: %v", respJson.ErrorCode, respJson.ErrorDescription)
+			return newProviderError(antiCaptchaErrors, a.name, "", taskId, respJson.ErrorCode, respJson.ErrorDescription)
 		}
 
 		return nil
 	}
 }
 
-var _ IProvider = (*AntiCaptcha)(nil)
+func (a *AntiCaptcha) ReportCorrect(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) error {
+	switch captchaType {
+	case CaptchaTypeRecaptchaV2, CaptchaTypeRecaptchaV3:
+		return a.Report("/reportCorrectRecaptcha", taskId, settings)(ctx)
+	}
+
+	return reportUnsupportedError(a.name, captchaType, taskId)
+}
+
+func (a *AntiCaptcha) ReportIncorrect(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) error {
+	switch captchaType {
+	case CaptchaTypeImage:
+		return a.Report("/reportIncorrectImageCaptcha", taskId, settings)(ctx)
+	case CaptchaTypeRecaptchaV2, CaptchaTypeRecaptchaV3:
+		return a.Report("/reportIncorrectRecaptcha", taskId, settings)(ctx)
+	case CaptchaTypeHCaptcha:
+		return a.Report("/reportIncorrectHcaptcha", taskId, settings)(ctx)
+	}
+
+	return reportUnsupportedError(a.name, captchaType, taskId)
+}
+
+func (a *AntiCaptcha) Balance(ctx context.Context, settings *Settings) (float64, error) {
+	type response struct {
+		ErrorID          int     `json:"errorId"`
+		ErrorCode        string  `json:"errorCode"`
+		ErrorDescription string  `json:"errorDescription"`
+		Balance          float64 `json:"balance"`
+	}
+
+	if a.apiKey == "" {
+		return 0, emptyKeyError(a.name)
+	}
+
+	jsonValue, err := json.Marshal(map[string]string{"clientKey": a.apiKey})
+	if err != nil {
+		return 0, err
+	}
+
+	respBody, err := doRequest(ctx, settings, a.name, requestPoll, http.MethodPost, a.baseUrl+"/getBalance", "application/json", jsonValue)
+	if err != nil {
+		return 0, err
+	}
+
+	var respJson response
+	if err := json.Unmarshal(respBody, &respJson); err != nil {
+		return 0, err
+	}
+
+	if respJson.ErrorID != 0 {
+		return 0, newProviderError(antiCaptchaErrors, a.name, "", "", respJson.ErrorCode, respJson.ErrorDescription)
+	}
+
+	return respJson.Balance, nil
+}
+
+var (
+	_ IProvider        = (*AntiCaptcha)(nil)
+	_ IBalanceProvider = (*AntiCaptcha)(nil)
+	_ IReporter        = (*AntiCaptcha)(nil)
+	_ IAsyncProvider   = (*AntiCaptcha)(nil)
+)
@@ -0,0 +1,122 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/packman80/anticaptcha/anticaptchatest"
+)
+
+// offlineOptions poll the stand-in servers without waiting
+var offlineOptions = []Option{WithInitialWaitTime(0), WithPollInterval(time.Millisecond)}
+
+func TestAntiCaptchaOffline(t *testing.T) {
+	srv := anticaptchatest.NewAntiCaptchaServer()
+	defer srv.Close()
+	srv.SetKey("key")
+	srv.Enqueue(anticaptchatest.Task{NotReady: 2, Solution: "token", Cost: 0.002})
+
+	cs := NewCaptchaSolver(NewCustomAntiCaptcha(srv.URL, "key"), offlineOptions...)
+	resp, err := cs.SolveRecaptchaV2(context.Background(), &RecaptchaV2Payload{EndpointUrl: "https://example.com", EndpointKey: "site"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	detailed := resp.(IDetailedCaptchaResponse)
+	if solution, taskId := resp.Solution(); solution != "token" || taskId != "1" || detailed.Cost() != 0.002 || detailed.Polls() != 3 {
+		t.Errorf("unexpected response %q for task %q, cost %v after %d polls", solution, taskId, detailed.Cost(), detailed.Polls())
+	}
+
+	created := srv.RequestsTo("/createTask")
+	if len(created) != 1 {
+		t.Fatalf("expected one task to be created, got %d", len(created))
+	}
+
+	task, _ := created[0].JSON["task"].(map[string]any)
+	if task["type"] != "NoCaptchaTaskProxyless" || task["websiteKey"] != "site" || created[0].Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected task %v", created[0].JSON)
+	}
+
+	if err := cs.ReportIncorrect(context.Background(), resp); err != nil {
+		t.Errorf("report failed: %v", err)
+	}
+
+	if balance, err := cs.Balance(context.Background()); err != nil || balance != 10 {
+		t.Errorf("unexpected balance %v: %v", balance, err)
+	}
+}
+
+func TestAntiCaptchaOfflineStringTaskIds(t *testing.T) {
+	srv := anticaptchatest.NewAntiCaptchaServer()
+	defer srv.Close()
+	srv.SetStringTaskIds(true)
+
+	cs := NewCaptchaSolver(NewCustomAntiCaptcha(srv.URL, "key"), offlineOptions...)
+	resp, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, taskId := resp.Solution(); solution != anticaptchatest.DefaultSolution || taskId != "00000000-0000-4000-8000-000000000001" {
+		t.Errorf("unexpected solution %q for task %q", solution, taskId)
+	}
+}
+
+func TestAntiCaptchaOfflineErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		task anticaptchatest.Task
+		err  error
+	}{
+		{"zero balance", anticaptchatest.Task{CreateError: "ERROR_ZERO_BALANCE"}, ErrZeroBalance},
+		{"unsolvable", anticaptchatest.Task{NotReady: 1, Error: "ERROR_CAPTCHA_UNSOLVABLE"}, ErrUnsolvable},
+		{"wrong key", anticaptchatest.Task{}, ErrInvalidKey},
+	}
+
+	for _, test := range tests {
+		srv := anticaptchatest.NewAntiCaptchaServer()
+		srv.Enqueue(test.task)
+		if test.err == ErrInvalidKey {
+			srv.SetKey("other")
+		}
+
+		cs := NewCaptchaSolver(NewCustomAntiCaptcha(srv.URL, "key"), offlineOptions...)
+		_, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+		if !errors.Is(err, test.err) {
+			t.Errorf("%v: expected %v, got %v", test.name, test.err, err)
+		}
+
+		srv.Close()
+	}
+}
+
+func TestAntiCaptchaOfflineMalformed(t *testing.T) {
+	srv := anticaptchatest.NewAntiCaptchaServer()
+	defer srv.Close()
+	srv.Enqueue(anticaptchatest.Task{MalformedResult: true})
+
+	cs := NewCaptchaSolver(NewCustomAntiCaptcha(srv.URL, "key"), offlineOptions...)
+	if _, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{Base64String: "aW1hZ2U="}); err == nil {
+		t.Fatal("expected malformed JSON to fail the task")
+	}
+
+	if polls := len(srv.RequestsTo("/getTaskResult")); polls != 1 {
+		t.Errorf("expected malformed JSON not to be retried, polled %d times", polls)
+	}
+}
+
+func TestAntiCaptchaOfflineSlow(t *testing.T) {
+	srv := anticaptchatest.NewAntiCaptchaServer()
+	defer srv.Close()
+	srv.Enqueue(anticaptchatest.Task{Delay: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	cs := NewCaptchaSolver(NewCustomAntiCaptcha(srv.URL, "key"), offlineOptions...)
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the slow response to be cut short by the context, got %v", err)
+	}
+}
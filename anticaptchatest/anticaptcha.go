@@ -0,0 +1,88 @@
+package anticaptchatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewAntiCaptchaServer emulates the JSON API of AntiCaptcha and compatible providers like CapMonster Cloud and
+// CapSolver, for anticaptcha.NewCustomAntiCaptcha.
+func NewAntiCaptchaServer() *Server {
+	return newServer(serveAntiCaptcha)
+}
+
+func serveAntiCaptcha(s *Server, w http.ResponseWriter, r *http.Request, req *Request) {
+	if !s.validKey(req.Param("clientKey")) {
+		writeAntiCaptchaError(w, "ERROR_KEY_DOES_NOT_EXIST")
+		return
+	}
+
+	switch req.Path {
+	case "/createTask":
+		taskId, t := s.create()
+		delay(r, t)
+
+		switch {
+		case t.MalformedCreate:
+			writeMalformed(w)
+		case t.CreateError != "":
+			writeAntiCaptchaError(w, t.CreateError)
+		default:
+			writeJSON(w, map[string]any{"errorId": 0, "taskId": jsonTaskId(taskId)})
+		}
+	case "/getTaskResult":
+		t, ready := s.poll(req.Param("taskId"))
+		delay(r, t)
+
+		switch {
+		case t == nil:
+			writeAntiCaptchaError(w, "ERROR_NO_SUCH_CAPCHA_ID")
+		case !ready:
+			writeJSON(w, map[string]any{"errorId": 0, "status": "processing"})
+		case t.MalformedResult:
+			writeMalformed(w)
+		case t.Error != "":
+			writeAntiCaptchaError(w, t.Error)
+		default:
+			now := time.Now().Unix()
+			writeJSON(w, map[string]any{
+				"errorId": 0,
+				"status":  "ready",
+				"solution": map[string]any{
+					"text":               t.Solution,
+					"gRecaptchaResponse": t.Solution,
+				},
+				"cost":       t.Cost,
+				"createTime": now,
+				"endTime":    now,
+			})
+		}
+	case "/getBalance":
+		writeJSON(w, map[string]any{"errorId": 0, "balance": s.currentBalance()})
+	case "/reportCorrectRecaptcha", "/reportIncorrectImageCaptcha", "/reportIncorrectRecaptcha", "/reportIncorrectHcaptcha":
+		if s.task(req.Param("taskId")) == nil {
+			writeAntiCaptchaError(w, "ERROR_NO_SUCH_CAPCHA_ID")
+			return
+		}
+
+		writeJSON(w, map[string]any{"errorId": 0, "status": "success"})
+	default:
+		writeAntiCaptchaError(w, "ERROR_NO_SUCH_METHOD")
+	}
+}
+
+// jsonTaskId sends numeric task ids as JSON numbers like AntiCaptcha, the ids handed out by SetStringTaskIds
+// remain strings.
+func jsonTaskId(taskId string) any {
+	if _, err := strconv.Atoi(taskId); err == nil {
+		return json.Number(taskId)
+	}
+
+	return taskId
+}
+
+func writeAntiCaptchaError(w http.ResponseWriter, code string) {
+	writeJSON(w, map[string]any{"errorId": 1, "errorCode": code, "errorDescription": code})
+}
@@ -0,0 +1,38 @@
+package anticaptchatest
+
+import "net/http"
+
+// NewCapGuruServer emulates the API of CapGuru, for anticaptcha.NewCustomCapGuruCaptcha. Tasks are posted as JSON
+// to the root path and answered right away with the plain text solution or error code, so NotReady has no effect
+// and malformed results are empty answers. The balance is served by res.php like with 2Captcha.
+func NewCapGuruServer() *Server {
+	return newServer(serveCapGuru)
+}
+
+func serveCapGuru(s *Server, w http.ResponseWriter, r *http.Request, req *Request) {
+	if !s.validKey(req.Param("key")) {
+		w.Write([]byte("ERROR_WRONG_USER_KEY"))
+		return
+	}
+
+	switch req.Path {
+	case "/":
+		_, t := s.create()
+		delay(r, t)
+
+		switch {
+		case t.MalformedCreate, t.MalformedResult:
+			// the body is left empty
+		case t.CreateError != "":
+			w.Write([]byte(t.CreateError))
+		case t.Error != "":
+			w.Write([]byte(t.Error))
+		default:
+			w.Write([]byte(t.Solution))
+		}
+	case "/res.php":
+		serveRes(s, w, r, req)
+	default:
+		w.Write([]byte("ERROR_METHOD_CALL"))
+	}
+}
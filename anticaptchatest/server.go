@@ -0,0 +1,330 @@
+// Package anticaptchatest provides stand-in servers that speak the wire formats of the captcha providers, so that
+// code using the anticaptcha package can be tested offline. Every server is an httptest.Server whose URL is passed
+// to the custom constructors of the providers, e.g.
+//
+//	srv := anticaptchatest.NewTwoCaptchaServer()
+//	defer srv.Close()
+//	srv.Enqueue(anticaptchatest.Task{NotReady: 2, Solution: "answer"})
+//
+//	cs := anticaptcha.NewCaptchaSolver(anticaptcha.NewCustomTwoCaptcha(srv.URL, "key"))
+//
+// Tasks are scripted in the order they are submitted and every request is captured for assertions.
+package anticaptchatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultSolution is the answer to tasks that weren't scripted
+const DefaultSolution = "solution"
+
+// Task scripts how a submitted task is answered.
+type Task struct {
+	// NotReady is the amount of polls that are answered with not ready before the result
+	NotReady int
+
+	// Solution is the answer once the task is ready, DefaultSolution when empty
+	Solution string
+
+	// CreateError is the error code the submission is refused with, e.g. ERROR_ZERO_BALANCE
+	CreateError string
+
+	// Error is the error code the task fails with once it is ready, e.g. ERROR_CAPTCHA_UNSOLVABLE
+	Error string
+
+	// MalformedCreate and MalformedResult answer the submission or the result with JSON that can't be parsed
+	MalformedCreate bool
+	MalformedResult bool
+
+	// Delay is waited before every response to requests for the task
+	Delay time.Duration
+
+	// Cost is the cost reported with the result, only AntiCaptcha reports it
+	Cost float64
+}
+
+// Request is a request received by a server.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Query  url.Values
+
+	// Body is the raw body, Form the parameters of a form body and JSON the fields of a JSON body
+	Body []byte
+	Form url.Values
+	JSON map[string]any
+}
+
+// Param returns the parameter name from the query, the form or the JSON body of the request.
+func (r Request) Param(name string) string {
+	if v := r.Query.Get(name); v != "" {
+		return v
+	}
+
+	if v := r.Form.Get(name); v != "" {
+		return v
+	}
+
+	if v, ok := r.JSON[name]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+
+	return ""
+}
+
+// Server emulates the API of a provider. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	key           string
+	balance       float64
+	stringTaskIds bool
+	defaultTask   Task
+	queue         []Task
+	tasks         map[string]*task
+	requests      []Request
+	lastId        int
+}
+
+// task is the state of a submitted task
+type task struct {
+	Task
+	polls int
+}
+
+func newServer(handler func(s *Server, w http.ResponseWriter, r *http.Request, req *Request)) *Server {
+	s := &Server{
+		balance: 10,
+		tasks:   map[string]*task{},
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := capture(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, *req)
+		s.mu.Unlock()
+
+		handler(s, w, r, req)
+	}))
+
+	return s
+}
+
+// capture reads the request, parsing form and JSON bodies.
+func capture(r *http.Request) (*Request, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Query:  r.URL.Query(),
+		Body:   body,
+		Form:   url.Values{},
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		if req.Form, err = url.ParseQuery(string(body)); err != nil {
+			return nil, err
+		}
+	case len(bytes.TrimSpace(body)) > 0 && (mediaType == "application/json" || body[0] == '{'):
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&req.JSON); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// Enqueue scripts the next submitted tasks, tasks submitted after the queue ran out follow the default task.
+func (s *Server) Enqueue(tasks ...Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = append(s.queue, tasks...)
+}
+
+// SetDefault sets how tasks that weren't enqueued are answered, they are solved right away with
+// DefaultSolution unless set.
+func (s *Server) SetDefault(task Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaultTask = task
+}
+
+// SetKey makes the server refuse requests that don't carry the api key, any key is accepted when it is empty.
+func (s *Server) SetKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.key = key
+}
+
+// SetBalance sets the balance that is reported, it is 10 by default.
+func (s *Server) SetBalance(balance float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.balance = balance
+}
+
+// SetStringTaskIds makes the server hand out task ids as JSON strings like CapSolver does instead of numbers,
+// only AntiCaptcha compatible servers send JSON numbers.
+func (s *Server) SetStringTaskIds(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stringTaskIds = enabled
+}
+
+// Requests returns the requests received so far, in the order they were received.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make([]Request, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// RequestsTo returns the requests received for the path, e.g. "/createTask" or "/res.php".
+func (s *Server) RequestsTo(path string) []Request {
+	var requests []Request
+	for _, req := range s.Requests() {
+		if req.Path == path {
+			requests = append(requests, req)
+		}
+	}
+
+	return requests
+}
+
+// Polls returns how often the result of the task was requested.
+func (s *Server) Polls(taskId string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.tasks[taskId]; ok {
+		return t.polls
+	}
+
+	return 0
+}
+
+// validKey reports whether the request carries the api key of the server.
+func (s *Server) validKey(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.key == "" || s.key == key
+}
+
+// create takes the next scripted task, it only gets an id when it isn't refused.
+func (s *Server) create() (string, *task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &task{Task: s.defaultTask}
+	if len(s.queue) > 0 {
+		t.Task = s.queue[0]
+		s.queue = s.queue[1:]
+	}
+
+	if t.Solution == "" {
+		t.Solution = DefaultSolution
+	}
+
+	if t.CreateError != "" || t.MalformedCreate {
+		return "", t
+	}
+
+	s.lastId++
+	taskId := strconv.Itoa(s.lastId)
+	if s.stringTaskIds {
+		taskId = fmt.Sprintf("00000000-0000-4000-8000-%012d", s.lastId)
+	}
+
+	s.tasks[taskId] = t
+	return taskId, t
+}
+
+// poll counts a poll of the task and reports whether it is ready, the task is nil when it doesn't exist.
+func (s *Server) poll(taskId string) (*task, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[taskId]
+	if !ok {
+		return nil, false
+	}
+
+	t.polls++
+	return t, t.polls > t.NotReady
+}
+
+// task returns the task, or nil when it doesn't exist.
+func (s *Server) task(taskId string) *task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tasks[taskId]
+}
+
+func (s *Server) currentBalance() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.balance
+}
+
+// delay waits before answering a request for the task, it stops early when the client went away.
+func delay(r *http.Request, t *task) {
+	if t == nil || t.Delay <= 0 {
+		return
+	}
+
+	select {
+	case <-time.After(t.Delay):
+	case <-r.Context().Done():
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeMalformed(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":`))
+}
@@ -0,0 +1,94 @@
+package anticaptchatest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func postJSON(t *testing.T, target, body string) map[string]any {
+	t.Helper()
+	resp, err := http.Post(target, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestAntiCaptchaServer(t *testing.T) {
+	srv := NewAntiCaptchaServer()
+	defer srv.Close()
+	srv.Enqueue(Task{NotReady: 2, Solution: "answer"}, Task{CreateError: "ERROR_ZERO_BALANCE"})
+
+	created := postJSON(t, srv.URL+"/createTask", `{"clientKey":"key","task":{"type":"ImageToTextTask"}}`)
+	if created["taskId"] != 1.0 {
+		t.Fatalf("expected a numeric task id, got %v", created)
+	}
+
+	for i, status := range []string{"processing", "processing", "ready"} {
+		result := postJSON(t, srv.URL+"/getTaskResult", `{"clientKey":"key","taskId":"1"}`)
+		if result["status"] != status {
+			t.Errorf("poll %d: expected %v, got %v", i+1, status, result)
+		}
+	}
+
+	if refused := postJSON(t, srv.URL+"/createTask", `{"clientKey":"key","task":{}}`); refused["errorCode"] != "ERROR_ZERO_BALANCE" {
+		t.Errorf("expected the scripted error, got %v", refused)
+	}
+
+	srv.SetStringTaskIds(true)
+	if created := postJSON(t, srv.URL+"/createTask", `{"clientKey":"key","task":{}}`); created["taskId"] != "00000000-0000-4000-8000-000000000002" {
+		t.Errorf("expected a string task id, got %v", created)
+	}
+
+	requests := srv.RequestsTo("/createTask")
+	if len(requests) != 3 || requests[0].Param("clientKey") != "key" || requests[0].JSON["task"] == nil {
+		t.Errorf("requests weren't captured: %+v", requests)
+	}
+
+	if srv.Polls("1") != 3 {
+		t.Errorf("expected 3 polls, got %d", srv.Polls("1"))
+	}
+}
+
+func TestTwoCaptchaServer(t *testing.T) {
+	srv := NewTwoCaptchaServer()
+	defer srv.Close()
+	srv.SetKey("key")
+	srv.Enqueue(Task{MalformedResult: true})
+
+	resp, err := http.PostForm(srv.URL+"/in.php", url.Values{"key": {"key"}, "method": {"base64"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + "/res.php?key=key&action=get&id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if json.Valid(body) {
+		t.Errorf("expected malformed JSON, got %s", body)
+	}
+
+	if rejected := postJSON(t, srv.URL+"/res.php?key=guess&action=getbalance", ""); rejected["request"] != "ERROR_WRONG_USER_KEY" {
+		t.Errorf("expected the key to be checked, got %v", rejected)
+	}
+
+	if form := srv.RequestsTo("/in.php")[0].Form; form.Get("method") != "base64" {
+		t.Errorf("expected the form to be captured, got %v", form)
+	}
+}
@@ -0,0 +1,80 @@
+package anticaptchatest
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// NewTwoCaptchaServer emulates the in.php/res.php API of 2Captcha, for anticaptcha.NewCustomTwoCaptcha.
+func NewTwoCaptchaServer() *Server {
+	return newServer(serveInRes)
+}
+
+// NewWhiteCaptchaServer emulates the API of WhiteCaptcha, for anticaptcha.NewCustomWhiteCaptcha. It is the
+// in.php/res.php API of 2Captcha, except that tasks are submitted as JSON, which is captured in Request.JSON.
+func NewWhiteCaptchaServer() *Server {
+	return newServer(serveInRes)
+}
+
+func serveInRes(s *Server, w http.ResponseWriter, r *http.Request, req *Request) {
+	if !s.validKey(req.Param("key")) {
+		writeInResError(w, "ERROR_WRONG_USER_KEY")
+		return
+	}
+
+	switch req.Path {
+	case "/in.php":
+		taskId, t := s.create()
+		delay(r, t)
+
+		switch {
+		case t.MalformedCreate:
+			writeMalformed(w)
+		case t.CreateError != "":
+			writeInResError(w, t.CreateError)
+		default:
+			writeJSON(w, map[string]any{"status": 1, "request": taskId})
+		}
+	case "/res.php":
+		serveRes(s, w, r, req)
+	default:
+		writeInResError(w, "ERROR_METHOD_CALL")
+	}
+}
+
+// serveRes answers res.php, which is shared by 2Captcha, WhiteCaptcha and CapGuru.
+func serveRes(s *Server, w http.ResponseWriter, r *http.Request, req *Request) {
+	switch action := req.Param("action"); action {
+	case "getbalance":
+		writeJSON(w, map[string]any{"status": 1, "request": strconv.FormatFloat(s.currentBalance(), 'f', 5, 64)})
+	case "reportgood", "reportbad":
+		if s.task(req.Param("id")) == nil {
+			writeInResError(w, "ERROR_WRONG_CAPTCHA_ID")
+			return
+		}
+
+		writeJSON(w, map[string]any{"status": 1, "request": "OK_REPORT_RECORDED"})
+	case "", "get":
+		t, ready := s.poll(req.Param("id"))
+		delay(r, t)
+
+		switch {
+		case t == nil:
+			writeInResError(w, "ERROR_WRONG_CAPTCHA_ID")
+		case !ready:
+			writeJSON(w, map[string]any{"status": 0, "request": "CAPCHA_NOT_READY"})
+		case t.MalformedResult:
+			writeMalformed(w)
+		case t.Error != "":
+			writeInResError(w, t.Error)
+		default:
+			writeJSON(w, map[string]any{"status": 1, "request": t.Solution})
+		}
+	default:
+		writeInResError(w, "ERROR_METHOD_CALL")
+	}
+}
+
+func writeInResError(w http.ResponseWriter, code string) {
+	writeJSON(w, map[string]any{"status": 0, "request": code, "error_text": code})
+}
@@ -0,0 +1,56 @@
+package anticaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// IBalanceProvider is implemented by providers that can report the balance of the account.
+type IBalanceProvider interface {
+	// Balance returns the balance of the account in the currency of the provider
+	Balance(ctx context.Context, settings *Settings) (float64, error)
+}
+
+func emptyKeyError(provider string) *ProviderError {
+	return &ProviderError{Provider: provider, Description: "api key is empty", Err: ErrInvalidKey}
+}
+
+// resBalance fetches the balance from APIs that follow the res.php?action=getbalance convention of 2Captcha.
+func resBalance(ctx context.Context, settings *Settings, provider, baseUrl, apiKey string) (float64, error) {
+	type response struct {
+		Status    int    `json:"status"`
+		Request   string `json:"request"`
+		ErrorText string `json:"error_text"`
+	}
+
+	if apiKey == "" {
+		return 0, emptyKeyError(provider)
+	}
+
+	body := &url.Values{}
+	body.Set("key", apiKey)
+	body.Set("action", "getbalance")
+	body.Set("json", "1")
+
+	fullURL := fmt.Sprintf("%v/res.php?%v", baseUrl, body.Encode())
+
+	respBody, err := doRequest(ctx, settings, provider, requestPoll, http.MethodGet, fullURL, "", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var jsonResp response
+	if err := json.Unmarshal(respBody, &jsonResp); err != nil {
+		return 0, err
+	}
+
+	if jsonResp.Status == 0 {
+		return 0, newProviderError(twoCaptchaErrors, provider, "", "", jsonResp.Request, jsonResp.ErrorText)
+	}
+
+	return strconv.ParseFloat(jsonResp.Request, 64)
+}
@@ -0,0 +1,50 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBalance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/getBalance":
+			w.Write([]byte(`{"errorId":0,"balance":12.5}`))
+		case "/res.php":
+			if r.URL.Query().Get("key") != "key" {
+				w.Write([]byte(`{"status":0,"request":"ERROR_WRONG_USER_KEY"}`))
+				return
+			}
+			w.Write([]byte(`{"status":1,"request":"3.75"}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	tests := []struct {
+		provider IProvider
+		want     float64
+		err      error
+	}{
+		{NewCustomAntiCaptcha(srv.URL, "key"), 12.5, nil},
+		{NewCustomTwoCaptcha(srv.URL, "key"), 3.75, nil},
+		{NewCustomWhiteCaptcha(srv.URL, "key"), 3.75, nil},
+		{NewCustomTwoCaptcha(srv.URL, "wrong"), 0, ErrInvalidKey},
+		{NewCustomAntiCaptcha(srv.URL, ""), 0, ErrInvalidKey},
+		{&stubProvider{name: "stub"}, 0, ErrUnsupported},
+	}
+
+	for _, tt := range tests {
+		balance, err := NewCaptchaSolver(tt.provider).Balance(ctx)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v: expected error %v, got %v", providerName(tt.provider), tt.err, err)
+		}
+
+		if balance != tt.want {
+			t.Errorf("%v: expected balance %v, got %v", providerName(tt.provider), tt.want, balance)
+		}
+	}
+}
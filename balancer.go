@@ -0,0 +1,226 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// BalanceStrategy decides which member of a BalancedProvider receives the next task.
+type BalanceStrategy int
+
+const (
+	// RoundRobin hands out tasks to the members in turn
+	RoundRobin BalanceStrategy = iota
+
+	// Weighted hands out tasks in proportion to the weight of the members
+	Weighted
+
+	// LeastInFlight hands out tasks to the member with the fewest unfinished tasks
+	LeastInFlight
+)
+
+// BalancerMember is a provider that takes part in a BalancedProvider.
+type BalancerMember struct {
+	Provider IProvider
+
+	// Weight is used by the Weighted strategy, a weight of 0 counts as 1
+	Weight int
+
+	// CaptchaTypes limits the member to these captcha types, the member is eligible for all types when empty
+	CaptchaTypes []CaptchaType
+}
+
+// BalancedProvider spreads tasks over several providers. A member that reports a captcha type as unsupported
+// is never picked for that type again, the task is handed to the next eligible member instead.
+type BalancedProvider struct {
+	strategy BalanceStrategy
+	members  []*balancerMember
+
+	mu   sync.Mutex
+	next int
+}
+
+type balancerMember struct {
+	BalancerMember
+
+	// current is the running weight of the smooth weighted round-robin
+	current     int
+	inFlight    int
+	unsupported map[CaptchaType]bool
+}
+
+func NewBalancedProvider(strategy BalanceStrategy, members ...BalancerMember) *BalancedProvider {
+	b := &BalancedProvider{strategy: strategy}
+	for _, member := range members {
+		if member.Weight <= 0 {
+			member.Weight = 1
+		}
+
+		b.members = append(b.members, &balancerMember{
+			BalancerMember: member,
+			unsupported:    map[CaptchaType]bool{},
+		})
+	}
+
+	return b
+}
+
+func (b *BalancedProvider) Name() string {
+	return "balanced"
+}
+
+// InFlight returns the amount of unfinished tasks per member, keyed by provider name
+func (b *BalancedProvider) InFlight() map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	inFlight := make(map[string]int, len(b.members))
+	for _, member := range b.members {
+		inFlight[providerName(member.Provider)] += member.inFlight
+	}
+
+	return inFlight
+}
+
+func (b *BalancedProvider) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	return b.solve(settings, CaptchaTypeImage, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveImageCaptcha(ctx, settings, payload)
+	})
+}
+
+func (b *BalancedProvider) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	return b.solve(settings, CaptchaTypeRecaptchaV2, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveRecaptchaV2(ctx, settings, payload)
+	})
+}
+
+func (b *BalancedProvider) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	return b.solve(settings, CaptchaTypeRecaptchaV3, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveRecaptchaV3(ctx, settings, payload)
+	})
+}
+
+func (b *BalancedProvider) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	return b.solve(settings, CaptchaTypeHCaptcha, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveHCaptcha(ctx, settings, payload)
+	})
+}
+
+func (b *BalancedProvider) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	return b.solve(settings, CaptchaTypeTurnstile, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveTurnstile(ctx, settings, payload)
+	})
+}
+
+func (b *BalancedProvider) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return b.solve(settings, CaptchaTypeCoordinates, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveCoordinates(ctx, settings, payload)
+	})
+}
+
+func (b *BalancedProvider) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return b.solve(settings, CaptchaTypeCustom, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveCustom(ctx, settings, payload)
+	})
+}
+
+func (b *BalancedProvider) solve(settings *Settings, captchaType CaptchaType, solve func(provider IProvider) (ICaptchaResponse, error)) (ICaptchaResponse, error) {
+	tried := map[*balancerMember]bool{}
+	var errs []error
+	for {
+		member := b.pick(captchaType, tried)
+		if member == nil {
+			// the members that were tried notified the observers themselves
+			if len(errs) == 0 {
+				return nil, refuseUnsupported(settings, b.Name(), captchaType)
+			}
+
+			return nil, errors.Join(errs...)
+		}
+
+		tried[member] = true
+		resp, err := solve(member.Provider)
+		b.done(member, captchaType, err)
+		if err == nil {
+			return withProvider(resp, providerName(member.Provider)), nil
+		}
+
+		if !errors.Is(err, ErrUnsupported) {
+			return nil, err
+		}
+
+		errs = append(errs, err)
+	}
+}
+
+// pick selects the member for the next task and counts the task as in flight.
+func (b *BalancedProvider) pick(captchaType CaptchaType, tried map[*balancerMember]bool) *balancerMember {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	eligible := make([]int, 0, len(b.members))
+	for i, member := range b.members {
+		if tried[member] || member.unsupported[captchaType] {
+			continue
+		}
+
+		if len(member.CaptchaTypes) > 0 && !slices.Contains(member.CaptchaTypes, captchaType) {
+			continue
+		}
+
+		eligible = append(eligible, i)
+	}
+
+	if len(eligible) == 0 {
+		return nil
+	}
+
+	var picked *balancerMember
+	switch b.strategy {
+	case Weighted:
+		total := 0
+		for _, i := range eligible {
+			member := b.members[i]
+			member.current += member.Weight
+			total += member.Weight
+			if picked == nil || member.current > picked.current {
+				picked = member
+			}
+		}
+		picked.current -= total
+	case LeastInFlight:
+		for _, i := range eligible {
+			if member := b.members[i]; picked == nil || member.inFlight < picked.inFlight {
+				picked = member
+			}
+		}
+	default:
+		// RoundRobin, the first eligible member at or after the cursor
+		index := eligible[0]
+		for _, i := range eligible {
+			if i >= b.next {
+				index = i
+				break
+			}
+		}
+		picked = b.members[index]
+		b.next = index + 1
+	}
+
+	picked.inFlight++
+	return picked
+}
+
+func (b *BalancedProvider) done(member *balancerMember, captchaType CaptchaType, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	member.inFlight--
+	if errors.Is(err, ErrUnsupported) {
+		member.unsupported[captchaType] = true
+	}
+}
+
+var _ IProvider = (*BalancedProvider)(nil)
@@ -0,0 +1,98 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBalancedProviderWeighted(t *testing.T) {
+	heavy := &stubProvider{name: "heavy", solution: "answer"}
+	light := &stubProvider{name: "light", solution: "answer"}
+
+	cs := NewCaptchaSolver(NewBalancedProvider(Weighted,
+		BalancerMember{Provider: heavy, Weight: 3},
+		BalancerMember{Provider: light, Weight: 1},
+	))
+
+	for i := 0; i < 8; i++ {
+		if _, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if heavy.calls != 6 || light.calls != 2 {
+		t.Errorf("expected a 6/2 split, got %d/%d", heavy.calls, light.calls)
+	}
+}
+
+func TestBalancedProviderSkipsUnsupported(t *testing.T) {
+	imageOnly := &stubProvider{name: "image-only", err: unsupportedError("image-only", CaptchaTypeRecaptchaV2)}
+	tokens := &stubProvider{name: "tokens", solution: "token"}
+
+	cs := NewCaptchaSolver(NewBalancedProvider(RoundRobin,
+		BalancerMember{Provider: imageOnly},
+		BalancerMember{Provider: tokens},
+	))
+
+	for i := 0; i < 4; i++ {
+		resp, err := cs.SolveRecaptchaV2(context.Background(), &RecaptchaV2Payload{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if solution, _ := resp.Solution(); solution != "token" {
+			t.Errorf("unexpected solution %q", solution)
+		}
+	}
+
+	if imageOnly.calls != 1 {
+		t.Errorf("a member that doesn't support a type should only be tried once, got %d calls", imageOnly.calls)
+	}
+}
+
+func TestBalancedProviderBadImage(t *testing.T) {
+	badImage := newProviderError(twoCaptchaErrors, "first", CaptchaTypeImage, "", "ERROR_IMAGE_TYPE_NOT_SUPPORTED", "")
+	first := &stubProvider{name: "first", err: badImage}
+	second := &stubProvider{name: "second", solution: "text"}
+
+	balancer := NewBalancedProvider(RoundRobin, BalancerMember{Provider: first}, BalancerMember{Provider: second})
+	cs := NewCaptchaSolver(balancer)
+	if _, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{}); !errors.Is(err, ErrBadPayload) {
+		t.Fatalf("expected ErrBadPayload, got %v", err)
+	}
+
+	if second.calls != 0 {
+		t.Error("the bad image was sent to another member")
+	}
+
+	// the member keeps receiving images
+	first.err = nil
+	for i := 0; i < 2; i++ {
+		if _, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if first.calls != 2 {
+		t.Errorf("a bad image disabled images on the member, got %d calls", first.calls)
+	}
+}
+
+func TestBalancedProviderCaptchaTypes(t *testing.T) {
+	images := &stubProvider{name: "images", solution: "text"}
+	tokens := &stubProvider{name: "tokens", solution: "token"}
+
+	cs := NewCaptchaSolver(NewBalancedProvider(LeastInFlight,
+		BalancerMember{Provider: images, CaptchaTypes: []CaptchaType{CaptchaTypeImage}},
+		BalancerMember{Provider: tokens, CaptchaTypes: []CaptchaType{CaptchaTypeHCaptcha}},
+	))
+
+	if _, err := cs.SolveHCaptcha(context.Background(), &HCaptchaPayload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if images.calls != 0 || tokens.calls != 1 {
+		t.Errorf("expected only the hcaptcha member to be used, got %d/%d", images.calls, tokens.calls)
+	}
+}
@@ -0,0 +1,131 @@
+package anticaptcha
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchOptions configures CaptchaSolver.SolveBatch.
+type BatchOptions struct {
+	// Concurrency is the maximum amount of payloads that are solved at the same time, defaults to 10
+	Concurrency int
+
+	// FailFast stops the batch after the first failure, payloads that were not solved yet fail with the
+	// cancellation error. By default every payload is attempted.
+	FailFast bool
+
+	// OnResult is called as soon as a payload is solved or failed, calls are never made concurrently
+	OnResult func(item BatchItem)
+}
+
+// BatchItem is the outcome of a single payload of a batch.
+type BatchItem struct {
+	// Index is the position of the payload in the batch
+	Index int
+
+	Payload  any
+	Response ICaptchaResponse
+	Err      error
+}
+
+// BatchResult is the outcome of a batch.
+type BatchResult struct {
+	// Items holds the outcome of every payload, in the order of the payloads
+	Items []BatchItem
+
+	Succeeded int
+	Failed    int
+
+	// TotalCost is the sum of the costs reported by the provider
+	TotalCost float64
+
+	// Elapsed is the wall time of the whole batch
+	Elapsed time.Duration
+}
+
+// SolveBatch solves payloads, which must be of the payload types like *ImageCaptchaPayload, with bounded concurrency.
+// The returned error is only set in FailFast mode, otherwise errors are reported per item.
+func (c *CaptchaSolver) SolveBatch(ctx context.Context, payloads []any, opts *BatchOptions, options ...Option) (*BatchResult, error) {
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := &BatchResult{Items: make([]BatchItem, len(payloads))}
+	indexes := make(chan int)
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	finish := func(item BatchItem) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		result.Items[item.Index] = item
+		if item.Err != nil {
+			result.Failed++
+			if firstErr == nil {
+				firstErr = item.Err
+				if opts.FailFast {
+					cancel()
+				}
+			}
+		} else {
+			result.Succeeded++
+			if detailed, ok := item.Response.(IDetailedCaptchaResponse); ok {
+				result.TotalCost += detailed.Cost()
+			}
+		}
+
+		if opts.OnResult != nil {
+			opts.OnResult(item)
+		}
+	}
+
+	for i := 0; i < concurrency && i < len(payloads); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				resp, err := c.Solve(ctx, payloads[index], options...)
+				finish(BatchItem{Index: index, Payload: payloads[index], Response: resp, Err: err})
+			}
+		}()
+	}
+
+	next := 0
+dispatch:
+	for ; next < len(payloads); next++ {
+		select {
+		case indexes <- next:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	// payloads that were never handed to a worker because the batch was stopped
+	for ; next < len(payloads); next++ {
+		finish(BatchItem{Index: next, Payload: payloads[next], Err: ctx.Err()})
+	}
+
+	result.Elapsed = time.Since(start)
+	if opts.FailFast && firstErr != nil {
+		return result, firstErr
+	}
+
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSolveBatch(t *testing.T) {
+	cs := NewCaptchaSolver(&stubProvider{name: "stub", solution: "answer", latency: 5 * time.Millisecond})
+
+	payloads := []any{&ImageCaptchaPayload{}, &RecaptchaV2Payload{}, &HCaptchaPayload{}, "not a payload"}
+	streamed := 0
+	result, err := cs.SolveBatch(context.Background(), payloads, &BatchOptions{
+		Concurrency: 2,
+		OnResult: func(item BatchItem) {
+			streamed++
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Succeeded != 3 || result.Failed != 1 || streamed != 4 {
+		t.Errorf("unexpected summary %+v, %d streamed", result, streamed)
+	}
+
+	for i, item := range result.Items {
+		if item.Index != i || item.Payload != payloads[i] {
+			t.Errorf("item %d is out of order: %+v", i, item)
+		}
+	}
+
+	if !errors.Is(result.Items[3].Err, ErrBadPayload) {
+		t.Errorf("expected ErrBadPayload for an unknown payload, got %v", result.Items[3].Err)
+	}
+}
+
+func TestSolveBatchFailFast(t *testing.T) {
+	cs := NewCaptchaSolver(&stubProvider{name: "stub", err: &ProviderError{Err: ErrZeroBalance}})
+
+	payloads := make([]any, 20)
+	for i := range payloads {
+		payloads[i] = &ImageCaptchaPayload{}
+	}
+
+	result, err := cs.SolveBatch(context.Background(), payloads, &BatchOptions{Concurrency: 1, FailFast: true})
+	if !errors.Is(err, ErrZeroBalance) {
+		t.Fatalf("expected ErrZeroBalance, got %v", err)
+	}
+
+	if result.Failed != len(payloads) || result.Succeeded != 0 {
+		t.Errorf("every payload should be accounted for, got %+v", result)
+	}
+
+	if !errors.Is(result.Items[len(payloads)-1].Err, context.Canceled) {
+		t.Errorf("expected the last payload to be cancelled, got %v", result.Items[len(payloads)-1].Err)
+	}
+}
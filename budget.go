@@ -0,0 +1,319 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded is returned by BudgetProvider when a task would go over one of its budgets.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Budget is a spending limit over a rolling window.
+type Budget struct {
+	// Name identifies the budget in errors and threshold callbacks
+	Name string
+
+	// Limit is the maximum amount spent within Window
+	Limit float64
+
+	// Window is the rolling window the spend is measured over, 0 means since the provider was created
+	Window time.Duration
+
+	// CaptchaType restricts the budget to a single captcha type, empty for every type
+	CaptchaType CaptchaType
+
+	// Label restricts the budget to tasks solved with the label, see WithBudgetLabel. Empty for every label
+	Label string
+}
+
+// BudgetUsage is the amount spent within a budget.
+type BudgetUsage struct {
+	Budget Budget
+	Spent  float64
+}
+
+// BudgetThreshold is passed to BudgetConfig.OnThreshold when a budget crosses one of the thresholds.
+type BudgetThreshold struct {
+	BudgetUsage
+
+	// Threshold is the fraction of the limit that was crossed, e.g. 0.8
+	Threshold float64
+}
+
+// BudgetConfig configures a BudgetProvider.
+type BudgetConfig struct {
+	Budgets []Budget
+
+	// Prices is the cost per task by captcha type, used when the provider doesn't report the cost of a task.
+	// They are also reserved while the task is being solved, so that concurrent tasks can't overshoot a budget.
+	Prices map[CaptchaType]float64
+
+	// Thresholds are the fractions of a limit that trigger OnThreshold, defaults to 0.8 and 1
+	Thresholds []float64
+
+	// OnThreshold is called when the spend of a budget crosses one of the thresholds. On rolling windows it is
+	// called again when the spend crosses the threshold again after dropping below it.
+	OnThreshold func(threshold BudgetThreshold)
+}
+
+// BudgetProvider tracks the money spent through a provider and refuses tasks once a budget is exhausted.
+// Failed tasks are assumed not to be charged.
+type BudgetProvider struct {
+	provider IProvider
+	config   BudgetConfig
+
+	mu         sync.Mutex
+	budgets    []*budgetState
+	thresholds []BudgetThreshold
+}
+
+type budgetState struct {
+	budget  Budget
+	spends  []*budgetSpend
+	settled float64
+	crossed map[float64]bool
+}
+
+type budgetSpend struct {
+	at   time.Time
+	cost float64
+	done bool
+}
+
+type budgetLabelKey struct{}
+
+// WithBudgetLabel labels the tasks solved with the context, so that they count against the budgets of the label.
+func WithBudgetLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, budgetLabelKey{}, label)
+}
+
+func budgetLabel(ctx context.Context) string {
+	label, _ := ctx.Value(budgetLabelKey{}).(string)
+	return label
+}
+
+func NewBudgetProvider(provider IProvider, config BudgetConfig) *BudgetProvider {
+	if len(config.Thresholds) == 0 {
+		config.Thresholds = []float64{0.8, 1}
+	}
+	sort.Float64s(config.Thresholds)
+
+	budgets := make([]*budgetState, len(config.Budgets))
+	for i, budget := range config.Budgets {
+		budgets[i] = &budgetState{budget: budget, crossed: map[float64]bool{}}
+	}
+
+	return &BudgetProvider{
+		provider: provider,
+		config:   config,
+		budgets:  budgets,
+	}
+}
+
+func (b *BudgetProvider) Name() string {
+	return providerName(b.provider)
+}
+
+// Usage returns the amount spent within every budget, including the tasks that are being solved
+func (b *BudgetProvider) Usage() []BudgetUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	usage := make([]BudgetUsage, len(b.budgets))
+	for i, state := range b.budgets {
+		usage[i] = BudgetUsage{Budget: state.budget, Spent: state.spent(now)}
+	}
+
+	return usage
+}
+
+// Balance forwards to the wrapped provider
+func (b *BudgetProvider) Balance(ctx context.Context, settings *Settings) (float64, error) {
+	provider, ok := b.provider.(IBalanceProvider)
+	if !ok {
+		return 0, &ProviderError{Provider: b.Name(), Description: "balance is not supported", Err: ErrUnsupported}
+	}
+
+	return provider.Balance(ctx, settings)
+}
+
+func (b *BudgetProvider) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	return b.solve(ctx, settings, CaptchaTypeImage, func() (ICaptchaResponse, error) {
+		return b.provider.SolveImageCaptcha(ctx, settings, payload)
+	})
+}
+
+func (b *BudgetProvider) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	return b.solve(ctx, settings, CaptchaTypeRecaptchaV2, func() (ICaptchaResponse, error) {
+		return b.provider.SolveRecaptchaV2(ctx, settings, payload)
+	})
+}
+
+func (b *BudgetProvider) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	return b.solve(ctx, settings, CaptchaTypeRecaptchaV3, func() (ICaptchaResponse, error) {
+		return b.provider.SolveRecaptchaV3(ctx, settings, payload)
+	})
+}
+
+func (b *BudgetProvider) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	return b.solve(ctx, settings, CaptchaTypeHCaptcha, func() (ICaptchaResponse, error) {
+		return b.provider.SolveHCaptcha(ctx, settings, payload)
+	})
+}
+
+func (b *BudgetProvider) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	return b.solve(ctx, settings, CaptchaTypeTurnstile, func() (ICaptchaResponse, error) {
+		return b.provider.SolveTurnstile(ctx, settings, payload)
+	})
+}
+
+func (b *BudgetProvider) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return b.solve(ctx, settings, CaptchaTypeCoordinates, func() (ICaptchaResponse, error) {
+		return b.provider.SolveCoordinates(ctx, settings, payload)
+	})
+}
+
+func (b *BudgetProvider) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return b.solve(ctx, settings, CaptchaTypeCustom, func() (ICaptchaResponse, error) {
+		return b.provider.SolveCustom(ctx, settings, payload)
+	})
+}
+
+func (b *BudgetProvider) solve(ctx context.Context, settings *Settings, captchaType CaptchaType, solve func() (ICaptchaResponse, error)) (ICaptchaResponse, error) {
+	spend, err := b.reserve(captchaType, budgetLabel(ctx))
+	if err != nil {
+		settings.observeRefused(b.Name(), captchaType, err)
+		return nil, err
+	}
+
+	resp, err := solve()
+
+	cost := 0.0
+	if err == nil {
+		cost = b.config.Prices[captchaType]
+		if detailed, ok := resp.(IDetailedCaptchaResponse); ok && detailed.Cost() > 0 {
+			cost = detailed.Cost()
+		}
+	}
+
+	b.settle(spend, cost)
+	return resp, err
+}
+
+// reserve checks the budgets that apply to the task and reserves its expected price on them.
+func (b *BudgetProvider) reserve(captchaType CaptchaType, label string) (*budgetSpend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	price := b.config.Prices[captchaType]
+
+	var applicable []*budgetState
+	for _, state := range b.budgets {
+		if !state.applies(captchaType, label) {
+			continue
+		}
+
+		spent := state.spent(now)
+		if spent >= state.budget.Limit || (price > 0 && spent+price > state.budget.Limit) {
+			return nil, &ProviderError{
+				Provider:    b.Name(),
+				CaptchaType: captchaType,
+				Description: fmt.Sprintf("budget %q exceeded: spent %v of %v", state.budget.Name, spent, state.budget.Limit),
+				Err:         ErrBudgetExceeded,
+			}
+		}
+
+		applicable = append(applicable, state)
+	}
+
+	spend := &budgetSpend{at: now, cost: price}
+	for _, state := range applicable {
+		state.spends = append(state.spends, spend)
+	}
+
+	return spend, nil
+}
+
+// settle replaces the reserved price with the actual cost and calls OnThreshold for the thresholds that were crossed.
+func (b *BudgetProvider) settle(spend *budgetSpend, cost float64) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	spend.cost = cost
+	spend.done = true
+
+	now := time.Now()
+	for _, state := range b.budgets {
+		if state.budget.Limit <= 0 {
+			continue
+		}
+
+		spent := state.spent(now)
+		for _, threshold := range b.config.Thresholds {
+			crossed := spent >= threshold*state.budget.Limit
+			if crossed && !state.crossed[threshold] {
+				b.thresholds = append(b.thresholds, BudgetThreshold{
+					BudgetUsage: BudgetUsage{Budget: state.budget, Spent: spent},
+					Threshold:   threshold,
+				})
+			}
+
+			state.crossed[threshold] = crossed
+		}
+	}
+}
+
+// unlock releases b.mu and only then calls OnThreshold, so that the callback can use the provider.
+func (b *BudgetProvider) unlock() {
+	thresholds := b.thresholds
+	b.thresholds = nil
+	b.mu.Unlock()
+
+	if b.config.OnThreshold == nil {
+		return
+	}
+
+	for _, threshold := range thresholds {
+		b.config.OnThreshold(threshold)
+	}
+}
+
+func (s *budgetState) applies(captchaType CaptchaType, label string) bool {
+	return (s.budget.CaptchaType == "" || s.budget.CaptchaType == captchaType) &&
+		(s.budget.Label == "" || s.budget.Label == label)
+}
+
+// spent drops the spends that fell out of the window and sums the others, b.mu must be held.
+// Budgets without a window fold finished spends into a running total instead.
+func (s *budgetState) spent(now time.Time) float64 {
+	kept := s.spends[:0]
+	for _, spend := range s.spends {
+		switch {
+		case s.budget.Window > 0 && spend.done && now.Sub(spend.at) > s.budget.Window:
+		case s.budget.Window <= 0 && spend.done:
+			s.settled += spend.cost
+		default:
+			kept = append(kept, spend)
+		}
+	}
+	clear(s.spends[len(kept):])
+	s.spends = kept
+
+	total := s.settled
+	for _, spend := range s.spends {
+		total += spend.cost
+	}
+
+	return total
+}
+
+var (
+	_ IProvider        = (*BudgetProvider)(nil)
+	_ IBalanceProvider = (*BudgetProvider)(nil)
+)
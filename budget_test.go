@@ -0,0 +1,99 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBudgetProvider(t *testing.T) {
+	var thresholds []BudgetThreshold
+	budget := NewBudgetProvider(&stubProvider{name: "stub", solution: "answer"}, BudgetConfig{
+		Budgets: []Budget{
+			{Name: "total", Limit: 0.9},
+			{Name: "custom", Limit: 0.5, CaptchaType: CaptchaTypeCustom},
+			{Name: "scraper", Limit: 0.2, Label: "scraper"},
+		},
+		Prices: map[CaptchaType]float64{CaptchaTypeImage: 0.1, CaptchaTypeCustom: 0.25},
+		OnThreshold: func(threshold BudgetThreshold) {
+			thresholds = append(thresholds, threshold)
+		},
+	})
+
+	cs := NewCaptchaSolver(budget)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := cs.SolveCustom(ctx, &CustomPayload{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := cs.SolveCustom(ctx, &CustomPayload{}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the custom budget to be exhausted, got %v", err)
+	}
+
+	// other types still fit in the total budget
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	labeled := WithBudgetLabel(ctx, "scraper")
+	for i := 0; i < 2; i++ {
+		if _, err := cs.SolveImageCaptcha(labeled, &ImageCaptchaPayload{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := cs.SolveImageCaptcha(labeled, &ImageCaptchaPayload{}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the label budget to be exhausted, got %v", err)
+	}
+
+	usage := budget.Usage()
+	if usage[0].Spent < 0.79 || usage[0].Spent > 0.81 {
+		t.Errorf("expected 0.8 spent in total, got %v", usage[0].Spent)
+	}
+
+	crossed := map[string]int{}
+	for _, threshold := range thresholds {
+		crossed[threshold.Budget.Name]++
+	}
+
+	// custom and scraper crossed 80% and 100%, the total crossed 80%
+	if crossed["custom"] != 2 || crossed["scraper"] != 2 || crossed["total"] != 1 {
+		t.Errorf("unexpected thresholds %+v", thresholds)
+	}
+}
+
+func TestBudgetProviderWindow(t *testing.T) {
+	stub := &stubProvider{name: "stub", err: &ProviderError{Err: ErrUnsolvable}}
+	budget := NewBudgetProvider(stub, BudgetConfig{
+		Budgets: []Budget{{Limit: 0.1, Window: 20 * time.Millisecond}},
+		Prices:  map[CaptchaType]float64{CaptchaTypeImage: 0.1},
+	})
+
+	cs := NewCaptchaSolver(budget)
+	ctx := context.Background()
+
+	// failed tasks are not charged
+	for i := 0; i < 3; i++ {
+		if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); !errors.Is(err, ErrUnsolvable) {
+			t.Fatalf("expected the provider error, got %v", err)
+		}
+	}
+
+	stub.err = nil
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the budget to be exhausted, got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); err != nil {
+		t.Fatalf("expected the spend to have left the window, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// defaultCallbackGrace is how long a callback is waited for when WithCallback was given no grace period
+const defaultCallbackGrace = 3 * time.Minute
+
+// CallbackReceiver receives the callbacks providers send when a task is solved, instead of the result being polled.
+// It is implemented by the callback package, which serves the URL the providers post to.
+type CallbackReceiver interface {
+	// URL returns the public URL the provider posts the callbacks of its tasks to
+	URL(provider string) string
+
+	// Wait blocks until the callback of the task arrived, it returns the error of ctx when it is done first
+	Wait(ctx context.Context, provider, taskId string) error
+}
+
+// WithCallback sends the URL of the receiver along with new tasks, so that the result is fetched as soon as the
+// provider calls back. When no callback arrived within grace of submitting the task, the result is polled.
+// Only AntiCaptcha (callbackUrl) and 2Captcha (pingback) compatible providers support callbacks, tasks of other
+// providers are polled right away.
+func WithCallback(receiver CallbackReceiver, grace time.Duration) Option {
+	return func(s *Settings) {
+		s.callback = receiver
+		s.callbackGrace = grace
+	}
+}
+
+// callbackURL returns the URL the provider has to call back, or an empty string when callbacks are disabled.
+func (s *Settings) callbackURL(provider string) string {
+	if s.callback == nil {
+		return ""
+	}
+
+	return s.callback.URL(provider)
+}
+
+// waitCallback waits for the callback of the task until the grace period or the solve deadline ends, it reports
+// whether the callback arrived. The error is only set when ctx is done.
+func (s *Settings) waitCallback(ctx context.Context, handle *TaskHandle, deadline time.Time) (bool, error) {
+	grace := s.callbackGrace
+	if grace <= 0 {
+		grace = defaultCallbackGrace
+	}
+
+	until := handle.SubmittedAt.Add(grace)
+	if !deadline.IsZero() && deadline.Before(until) {
+		until = deadline
+	}
+
+	waitCtx, cancel := context.WithDeadline(ctx, until)
+	defer cancel()
+
+	err := s.callback.Wait(waitCtx, handle.Provider, handle.TaskId)
+	if err == nil {
+		return true, nil
+	}
+
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false, nil
+	}
+
+	return false, err
+}
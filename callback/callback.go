@@ -0,0 +1,213 @@
+// Package callback receives the callbacks providers send when a task is solved, so that solvers don't have to
+// keep polling for the result. 2Captcha calls them pingbacks, AntiCaptcha compatible APIs callbackUrl.
+//
+//	receiver := callback.NewReceiver("https://example.com/captcha", "")
+//	http.Handle("/captcha", receiver)
+//	cs.SetCallback(receiver, 2*time.Minute)
+//
+// Neither provider signs its callbacks, the URL carries a secret token instead, which is compared in constant time.
+// A callback only wakes the solver waiting for the task, which then fetches the result from the provider itself,
+// so a forged callback can't inject a solution. 2Captcha only calls back URLs whose domain was registered in the
+// pingback settings of the account.
+package callback
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/packman80/anticaptcha"
+)
+
+// maxBodySize limits the callbacks read, AntiCaptcha posts the full task result
+const maxBodySize = 1 << 20
+
+// retention is how long callbacks that arrive before the solver started waiting for them are kept
+const retention = 10 * time.Minute
+
+// Receiver is an http.Handler that receives callbacks and wakes the solvers waiting for them.
+// It implements anticaptcha.CallbackReceiver and is safe for concurrent use.
+type Receiver struct {
+	publicURL string
+	token     string
+
+	mu    sync.Mutex
+	tasks map[taskKey]*task
+}
+
+type taskKey struct {
+	provider string
+	taskId   string
+}
+
+// task is closed once its callback arrived, waiters holds the amount of solvers waiting for it
+type task struct {
+	done      chan struct{}
+	arrivedAt time.Time
+	waiters   int
+}
+
+// NewReceiver creates a receiver for callbacks sent to publicURL, which has to be reachable by the providers.
+// The token authenticates the callbacks, a random token is generated when it is empty. Receivers behind a load
+// balancer have to share the token.
+func NewReceiver(publicURL, token string) *Receiver {
+	if token == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		token = hex.EncodeToString(b)
+	}
+
+	return &Receiver{
+		publicURL: publicURL,
+		token:     token,
+		tasks:     map[taskKey]*task{},
+	}
+}
+
+// URL returns the public URL the provider has to post its callbacks to, it carries the provider and the token.
+func (r *Receiver) URL(provider string) string {
+	u, err := url.Parse(r.publicURL)
+	if err != nil {
+		return r.publicURL
+	}
+
+	query := u.Query()
+	query.Set("provider", provider)
+	query.Set("token", r.token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Wait blocks until the callback of the task arrived, it returns the error of ctx when it is done first.
+func (r *Receiver) Wait(ctx context.Context, provider, taskId string) error {
+	key := taskKey{provider, taskId}
+
+	r.mu.Lock()
+	t := r.task(key)
+	t.waiters++
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		// the callback was consumed, or nobody waits for it anymore
+		if t.waiters--; t.waiters == 0 && r.tasks[key] == t {
+			delete(r.tasks, key)
+		}
+	}()
+
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ServeHTTP handles the callbacks, 2Captcha posts a form with the id of the task and AntiCaptcha the JSON result
+// of the task.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("token")), []byte(r.token)) != 1 {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, maxBodySize)
+	taskId, err := parseTaskId(req)
+	if err != nil || taskId == "" {
+		http.Error(w, "missing task id", http.StatusBadRequest)
+		return
+	}
+
+	r.notify(taskKey{query.Get("provider"), taskId})
+	w.Write([]byte("OK"))
+}
+
+// parseTaskId reads the task id from a JSON callback, where it is a number with AntiCaptcha and a string with
+// some compatible APIs, or from the form posted by 2Captcha.
+func parseTaskId(req *http.Request) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		if err := req.ParseForm(); err != nil {
+			return "", err
+		}
+
+		return req.PostForm.Get("id"), nil
+	}
+
+	var body struct {
+		TaskId json.RawMessage `json:"taskId"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	var taskId string
+	if err := json.Unmarshal(body.TaskId, &taskId); err == nil {
+		return taskId, nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(body.TaskId, &number); err != nil {
+		return "", err
+	}
+
+	if _, err := strconv.ParseInt(number.String(), 10, 64); err != nil {
+		return "", err
+	}
+
+	return number.String(), nil
+}
+
+// notify wakes the solvers waiting for the task, or keeps the callback for the solver that is about to wait.
+func (r *Receiver) notify(key taskKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune()
+	t := r.task(key)
+	if t.arrivedAt.IsZero() {
+		t.arrivedAt = time.Now()
+		close(t.done)
+	}
+}
+
+// task returns the task of key, creating it when needed. The mutex must be held.
+func (r *Receiver) task(key taskKey) *task {
+	t, ok := r.tasks[key]
+	if !ok {
+		t = &task{done: make(chan struct{})}
+		r.tasks[key] = t
+	}
+
+	return t
+}
+
+// prune forgets the callbacks nobody waited for within the retention. The mutex must be held.
+func (r *Receiver) prune() {
+	for key, t := range r.tasks {
+		if t.waiters == 0 && !t.arrivedAt.IsZero() && time.Since(t.arrivedAt) > retention {
+			delete(r.tasks, key)
+		}
+	}
+}
+
+var _ anticaptcha.CallbackReceiver = (*Receiver)(nil)
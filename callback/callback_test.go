@@ -0,0 +1,229 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/packman80/anticaptcha"
+)
+
+func post(t *testing.T, handler http.Handler, target, contentType, body string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestReceiver(t *testing.T) {
+	receiver := NewReceiver("https://example.com/captcha?source=test", "secret")
+	target := receiver.URL("2captcha")
+	if u, err := url.Parse(target); err != nil || u.Query().Get("token") != "secret" || u.Query().Get("source") != "test" {
+		t.Fatalf("unexpected callback url %q", target)
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"wrong token", "/captcha?provider=2captcha&token=guess", "application/x-www-form-urlencoded", "id=1", http.StatusForbidden},
+		{"missing task id", target, "application/x-www-form-urlencoded", "code=answer", http.StatusBadRequest},
+		{"malformed json", target, "application/json", "{", http.StatusBadRequest},
+		{"pingback", target, "application/x-www-form-urlencoded", "id=1&code=answer", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		if status := post(t, receiver, test.target, test.contentType, test.body); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.name, test.status, status)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET to be refused, got %d", rec.Code)
+	}
+
+	// the pingback arrived before anybody waited for it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := receiver.Wait(ctx, "2captcha", "1"); err != nil {
+		t.Fatalf("expected the early callback to be kept, got %v", err)
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := receiver.Wait(short, "anticaptcha", "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected callbacks to be kept per provider, got %v", err)
+	}
+}
+
+func TestReceiverWakesWaiter(t *testing.T) {
+	receiver := NewReceiver("https://example.com/captcha", "")
+	done := make(chan error, 1)
+	go func() {
+		done <- receiver.Wait(context.Background(), "anticaptcha", "7654321")
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	body := `{"taskId":7654321,"errorId":0,"status":"ready","solution":{"text":"answer"}}`
+	if status := post(t, receiver, receiver.URL("anticaptcha"), "application/json", body); status != http.StatusOK {
+		t.Fatalf("callback was refused with status %d", status)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the waiter wasn't woken up")
+	}
+}
+
+func TestSolveWithCallback(t *testing.T) {
+	receiver := NewReceiver("http://callback.test/captcha", "")
+	var (
+		polls    atomic.Int32
+		pingback atomic.Value
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/in.php":
+			r.ParseForm()
+			pingback.Store(r.PostForm.Get("pingback"))
+			w.Write([]byte(`{"status":1,"request":"99"}`))
+
+			// the provider calls back once the task is solved
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				post(t, receiver, r.PostForm.Get("pingback"), "application/x-www-form-urlencoded", "id=99&code=answer")
+			}()
+		case "/res.php":
+			polls.Add(1)
+			w.Write([]byte(`{"status":1,"request":"answer"}`))
+		}
+	}))
+	defer srv.Close()
+
+	provider := anticaptcha.NewCustomTwoCaptcha(srv.URL, "key")
+	cs := anticaptcha.NewCaptchaSolver(provider, anticaptcha.WithInitialWaitTime(time.Hour), anticaptcha.WithCallback(receiver, time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := cs.SolveImageCaptcha(ctx, &anticaptcha.ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, taskId := resp.Solution(); solution != "answer" || taskId != "99" {
+		t.Errorf("unexpected solution %q for task %q", solution, taskId)
+	}
+
+	if got := pingback.Load(); got != receiver.URL(provider.Name()) {
+		t.Errorf("expected the pingback url to be sent, got %q", got)
+	}
+
+	if polls.Load() != 1 {
+		t.Errorf("expected the result to be fetched once, got %d", polls.Load())
+	}
+}
+
+func TestSolveCallbackGrace(t *testing.T) {
+	receiver := NewReceiver("http://callback.test/captcha", "")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/createTask":
+			w.Write([]byte(`{"errorId":0,"taskId":42}`))
+		case "/getTaskResult":
+			w.Write([]byte(`{"errorId":0,"status":"ready","solution":{"text":"answer"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	cs := anticaptcha.NewCaptchaSolver(anticaptcha.NewCustomAntiCaptcha(srv.URL, "key"),
+		anticaptcha.WithInitialWaitTime(0),
+		anticaptcha.WithCallback(receiver, 50*time.Millisecond),
+	)
+
+	start := time.Now()
+	resp, err := cs.SolveImageCaptcha(context.Background(), &anticaptcha.ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, _ := resp.Solution(); solution != "answer" {
+		t.Errorf("unexpected solution %q", solution)
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected the callback to be waited for during the grace period, polled after %v", elapsed)
+	}
+}
+
+func TestSolveCallbackNotReady(t *testing.T) {
+	receiver := NewReceiver("http://callback.test/captcha", "")
+	var (
+		mu    sync.Mutex
+		polls []time.Time
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/in.php":
+			r.ParseForm()
+			w.Write([]byte(`{"status":1,"request":"99"}`))
+
+			// the callback arrives before the result can be fetched
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				post(t, receiver, r.PostForm.Get("pingback"), "application/x-www-form-urlencoded", "id=99&code=answer")
+			}()
+		case "/res.php":
+			mu.Lock()
+			polls = append(polls, time.Now())
+			first := len(polls) == 1
+			mu.Unlock()
+
+			if first {
+				w.Write([]byte(`{"status":0,"request":"CAPCHA_NOT_READY"}`))
+				return
+			}
+			w.Write([]byte(`{"status":1,"request":"answer"}`))
+		}
+	}))
+	defer srv.Close()
+
+	cs := anticaptcha.NewCaptchaSolver(anticaptcha.NewCustomTwoCaptcha(srv.URL, "key"),
+		anticaptcha.WithInitialWaitTime(time.Hour),
+		anticaptcha.WithPollInterval(50*time.Millisecond),
+		anticaptcha.WithCallback(receiver, time.Minute),
+	)
+
+	if _, err := cs.SolveImageCaptcha(context.Background(), &anticaptcha.ImageCaptchaPayload{Base64String: "aW1hZ2U="}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(polls) != 2 {
+		t.Fatalf("expected two polls, got %d", len(polls))
+	}
+
+	if gap := polls[1].Sub(polls[0]); gap < 40*time.Millisecond {
+		t.Errorf("expected the poll interval to be waited after the callback, polled again after %v", gap)
+	}
+}
@@ -1,19 +1,21 @@
 package anticaptcha
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
+	"maps"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/packman80/anticaptcha/internal"
 )
 
 type CapGuruCaptcha struct {
+	name    string
 	baseUrl string
 	apiKey  string
 }
@@ -26,6 +28,7 @@ type CapGuruCaptchaResponse struct {
 
 func NewCapGuruCaptcha(apiKey string) *CapGuruCaptcha {
 	return &CapGuruCaptcha{
+		name:    "capguru",
 		apiKey:  apiKey,
 		baseUrl: "http://api.cap.guru",
 	}
@@ -35,11 +38,17 @@ func NewCapGuruCaptcha(apiKey string) *CapGuruCaptcha {
 // have the exact same API as CapGuruCaptcha, thus allowing you to use these providers with ease.
 func NewCustomCapGuruCaptcha(baseUrl, apiKey string) *CapGuruCaptcha {
 	return &CapGuruCaptcha{
+		name:    nameFromBaseUrl(baseUrl, "capguru"),
 		baseUrl: baseUrl,
 		apiKey:  apiKey,
 	}
 }
 
+// Name returns the name of the provider as used in errors, e.g. "capguru" or the host of a custom baseUrl.
+func (a *CapGuruCaptcha) Name() string {
+	return a.name
+}
+
 func (a *CapGuruCaptcha) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
 	task := map[string]any{
 		"type": "ImageToTextTask",
@@ -47,7 +56,7 @@ func (a *CapGuruCaptcha) SolveImageCaptcha(ctx context.Context, settings *Settin
 		"case": payload.CaseSensitive,
 	}
 
-	result, err := a.solveTask(ctx, settings, task)
+	result, err := a.solveTask(ctx, settings, CaptchaTypeImage, task)
 	if err != nil {
 		return nil, err
 	}
@@ -55,52 +64,73 @@ func (a *CapGuruCaptcha) SolveImageCaptcha(ctx context.Context, settings *Settin
 }
 
 func (a *CapGuruCaptcha) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeRecaptchaV2)
 }
 
 func (a *CapGuruCaptcha) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeRecaptchaV3)
 }
 
 func (a *CapGuruCaptcha) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeHCaptcha)
 }
 
 func (a *CapGuruCaptcha) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeTurnstile)
 }
 
 func (a *CapGuruCaptcha) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeCoordinates)
 }
 
 func (a *CapGuruCaptcha) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
-	result, err := a.solveTask(ctx, settings, payload.Params)
+	// createTaskInstantResult adds the key to the task, which must not end up in the params of the caller
+	result, err := a.solveTask(ctx, settings, CaptchaTypeCustom, maps.Clone(payload.Params))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (a *CapGuruCaptcha) solveTask(ctx context.Context, settings *Settings, captchaType CaptchaType, task map[string]any) (*CaptchaResponse, error) {
+	// the answer comes with the response to the submission, so there is no task id
+	handle := &TaskHandle{Provider: a.name, CaptchaType: captchaType, SubmittedAt: time.Now()}
+	result, err := a.solveInstant(ctx, settings, handle, task)
 	if err != nil {
+		settings.observeFailed(handle, err)
 		return nil, err
 	}
 
+	settings.observeSolved(handle, result)
 	return result, nil
 }
 
-func (a *CapGuruCaptcha) solveTask(ctx context.Context, settings *Settings, task map[string]any) (*CaptchaResponse, error) {
-	answer, err := a.createTaskInstantResult(ctx, settings, task)
+func (a *CapGuruCaptcha) solveInstant(ctx context.Context, settings *Settings, handle *TaskHandle, task map[string]any) (*CaptchaResponse, error) {
+	answer, err := a.createTaskInstantResult(ctx, settings, handle.CaptchaType, task)
 	if err != nil {
 		return nil, err
 	}
+	settings.observeCreated(handle)
 
-	if err := internal.SleepWithContext(ctx, settings.initialWaitTime); err != nil {
+	if err := internal.SleepWithContext(ctx, settings.timing(a.name, handle.CaptchaType).InitialWait); err != nil {
 		return nil, err
 	}
 
 	if answer != "" {
-		return &CaptchaResponse{solution: answer, taskId: ""}, nil
+		return &CaptchaResponse{
+			solution:    answer,
+			provider:    a.name,
+			captchaType: handle.CaptchaType,
+			submittedAt: handle.SubmittedAt,
+			solvedAt:    time.Now(),
+		}, nil
 	}
 
-	return nil, errors.New("error")
+	return nil, &ProviderError{Provider: a.name, CaptchaType: handle.CaptchaType, Description: "empty answer", Err: ErrUnsolvable}
 }
 
-func (a *CapGuruCaptcha) createTaskInstantResult(ctx context.Context, settings *Settings, task map[string]any) (string, error) {
+func (a *CapGuruCaptcha) createTaskInstantResult(ctx context.Context, settings *Settings, captchaType CaptchaType, task map[string]any) (string, error) {
 	task["key"] = a.apiKey
 	task["json"] = 1
 	jsonValue, err := json.Marshal(task)
@@ -108,32 +138,35 @@ func (a *CapGuruCaptcha) createTaskInstantResult(ctx context.Context, settings *
 		return "", err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseUrl, bytes.NewBuffer(jsonValue))
+	respBody, err := doRequest(ctx, settings, a.name, requestCreate, http.MethodPost, a.baseUrl, "application/json", jsonValue)
 	if err != nil {
-		return "", err
+		return "", taskError(err, captchaType, "")
 	}
-	req.Header.Set("content-type", "application/json")
 
-	resp, err := settings.client.Do(req)
-	if err != nil {
-		return "", err
+	// errors are sent as a plain text code instead of an answer
+	answer := string(respBody)
+	if isErrorCode(strings.TrimSpace(answer)) {
+		return "", newProviderError(twoCaptchaErrors, a.name, captchaType, "", strings.TrimSpace(answer), "")
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+	return answer, nil
+}
+
+// errorCodePattern matches codes like ERROR_WRONG_USER_KEY or IP_BANNED. Answers to image captchas can be in
+// capitals too, but never contain an underscore.
+var errorCodePattern = regexp.MustCompile(`^[A-Z]+(_[A-Z0-9]+)+$`)
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// isErrorCode reports whether an instant answer is an error code, including codes that aren't mapped yet.
+func isErrorCode(answer string) bool {
+	if _, ok := twoCaptchaErrors[answer]; ok {
+		return true
 	}
 
-	return string(respBody), nil
+	return strings.HasPrefix(answer, "ERROR_") || errorCodePattern.MatchString(answer)
 }
 
 // nolint
-func (a *CapGuruCaptcha) createTask(ctx context.Context, settings *Settings, task map[string]any) (string, error) {
+func (a *CapGuruCaptcha) createTask(ctx context.Context, settings *Settings, captchaType CaptchaType, task map[string]any) (string, error) {
 	task["key"] = a.apiKey
 	task["json"] = 1
 	jsonValue, err := json.Marshal(task)
@@ -141,21 +174,9 @@ func (a *CapGuruCaptcha) createTask(ctx context.Context, settings *Settings, tas
 		return "", err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseUrl+"/in.php", bytes.NewBuffer(jsonValue))
+	respBody, err := doRequest(ctx, settings, a.name, requestCreate, http.MethodPost, a.baseUrl+"/in.php", "application/json", jsonValue)
 	if err != nil {
-		return "", err
-	}
-	req.Header.Set("content-type", "application/json")
-
-	resp, err := settings.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+		return "", taskError(err, captchaType, "")
 	}
 
 	var responseAsJSON CapGuruCaptchaResponse
@@ -164,14 +185,14 @@ func (a *CapGuruCaptcha) createTask(ctx context.Context, settings *Settings, tas
 	}
 
 	if responseAsJSON.Status == 0 {
-		return "", fmt.Errorf("%v", responseAsJSON.Request)
+		return "", newProviderError(twoCaptchaErrors, a.name, captchaType, "", responseAsJSON.Request, responseAsJSON.ErrorText)
 	}
 
 	return responseAsJSON.Request, nil
 }
 
 // nolint
-func (a *CapGuruCaptcha) getResult(ctx context.Context, settings *Settings, taskId string) (string, error) {
+func (a *CapGuruCaptcha) getResult(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) (*CaptchaResponse, error) {
 	body := &url.Values{}
 	body.Set("key", a.apiKey)
 	body.Set("json", "1")
@@ -179,39 +200,36 @@ func (a *CapGuruCaptcha) getResult(ctx context.Context, settings *Settings, task
 
 	fullURL := fmt.Sprintf("%v/res.php?%v", a.baseUrl, body.Encode())
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
-	if err != nil {
-		return "", err
-	}
-
-	resp, err := settings.client.Do(req)
+	respBody, err := doRequest(ctx, settings, a.name, requestPoll, http.MethodGet, fullURL, "", nil)
 	if err != nil {
-		return "", nil
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+		return nil, taskError(err, captchaType, taskId)
 	}
 
 	var respJson Response
 	if err := json.Unmarshal(respBody, &respJson); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if respJson.Status == 0 {
 		if respJson.Request == "CAPCHA_NOT_READY" {
-			return "", nil
+			return nil, nil
 		}
 
-		return "", fmt.Errorf("%v", respJson.Request)
+		return nil, newProviderError(twoCaptchaErrors, a.name, captchaType, taskId, respJson.Request, respJson.ErrorText)
 	}
-	return respJson.Request, nil
+	return &CaptchaResponse{solution: respJson.Request}, nil
+}
+
+// Report returns an error wrapping ErrUnsupported, CapGuru doesn't take reports
+func (a *CapGuruCaptcha) Report(ctx context.Context, action, taskId string, settings *Settings) error {
+	return reportUnsupportedError(a.name, "", taskId)
 }
 
-func (t *CapGuruCaptcha) Report(ctx context.Context, action, taskId string, settings *Settings) error {
-	return nil
+func (a *CapGuruCaptcha) Balance(ctx context.Context, settings *Settings) (float64, error) {
+	return resBalance(ctx, settings, a.name, a.baseUrl, a.apiKey)
 }
 
-var _ IProvider = (*CapGuruCaptcha)(nil)
+var (
+	_ IProvider        = (*CapGuruCaptcha)(nil)
+	_ IBalanceProvider = (*CapGuruCaptcha)(nil)
+)
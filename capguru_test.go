@@ -0,0 +1,70 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/packman80/anticaptcha/anticaptchatest"
+)
+
+func TestCapGuruOffline(t *testing.T) {
+	srv := anticaptchatest.NewCapGuruServer()
+	defer srv.Close()
+	srv.SetBalance(1.5)
+	srv.Enqueue(
+		anticaptchatest.Task{Solution: "answer"},
+		anticaptchatest.Task{CreateError: "ERROR_ZERO_BALANCE"},
+		anticaptchatest.Task{MalformedResult: true},
+		anticaptchatest.Task{CreateError: "ERROR_NEWLY_ADDED"},
+		anticaptchatest.Task{CreateError: "SERVICE_PAUSED"},
+		anticaptchatest.Task{Solution: "XK4PD"},
+	)
+
+	cs := NewCaptchaSolver(NewCustomCapGuruCaptcha(srv.URL, "key"), offlineOptions...)
+	ctx := context.Background()
+
+	resp, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, _ := resp.Solution(); solution != "answer" {
+		t.Errorf("unexpected solution %q", solution)
+	}
+
+	if task := srv.RequestsTo("/")[0]; task.JSON["type"] != "ImageToTextTask" || task.Param("key") != "key" || task.Param("json") != "1" {
+		t.Errorf("unexpected task %v", task.JSON)
+	}
+
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="}); !errors.Is(err, ErrZeroBalance) {
+		t.Errorf("expected ErrZeroBalance, got %v", err)
+	}
+
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="}); !errors.Is(err, ErrUnsolvable) {
+		t.Errorf("expected an empty answer to be unsolvable, got %v", err)
+	}
+
+	// codes that aren't mapped are errors all the same
+	for _, code := range []string{"ERROR_NEWLY_ADDED", "SERVICE_PAUSED"} {
+		_, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+		var providerErr *ProviderError
+		if !errors.As(err, &providerErr) || providerErr.Code != code || providerErr.Err != nil {
+			t.Errorf("expected an unmapped error for %v, got %v", code, err)
+		}
+	}
+
+	if resp, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="}); err != nil {
+		t.Errorf("expected an answer in capitals to be a solution, got %v", err)
+	} else if solution, _ := resp.Solution(); solution != "XK4PD" {
+		t.Errorf("unexpected solution %q", solution)
+	}
+
+	if balance, err := cs.Balance(ctx); err != nil || balance != 1.5 {
+		t.Errorf("unexpected balance %v: %v", balance, err)
+	}
+
+	if err := NewCustomCapGuruCaptcha(srv.URL, "key").Report(ctx, "reportbad", "1", NewSettings()); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected reports to be unsupported, got %v", err)
+	}
+}
@@ -1,16 +1,144 @@
 package anticaptcha
 
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
 type ICaptchaResponse interface {
 	// Solution will return the solution of the captcha as a string
 	Solution() (string, string)
 }
 
+// IDetailedCaptchaResponse exposes everything a provider sent back besides the solution itself.
+// Values that the provider does not report are left empty.
+type IDetailedCaptchaResponse interface {
+	ICaptchaResponse
+
+	// Provider is the name of the provider that solved the captcha
+	Provider() string
+
+	// CaptchaType is the type of captcha that was solved
+	CaptchaType() CaptchaType
+
+	// Cost is the price of the task as reported by the provider
+	Cost() float64
+
+	// SubmittedAt is the time the task was created
+	SubmittedAt() time.Time
+
+	// SolvedAt is the time the task was solved
+	SolvedAt() time.Time
+
+	// Polls is the amount of result requests that were made before the solution was ready
+	Polls() int
+
+	// SolveCount is the amount of workers that tried to solve the captcha
+	SolveCount() int
+
+	// IP is the address of the worker that solved the captcha
+	IP() string
+
+	// UserAgent is the user agent the token was minted with, it should be used when submitting the token
+	UserAgent() string
+
+	// Cookies are the cookies set while solving the captcha
+	Cookies() map[string]string
+
+	// RawSolution is the solution as it was returned by the provider
+	RawSolution() json.RawMessage
+}
+
 type CaptchaResponse struct {
 	solution, taskId string
+
+	provider    string
+	captchaType CaptchaType
+	cost        float64
+	submittedAt time.Time
+	solvedAt    time.Time
+	polls       int
+	solveCount  int
+	ip          string
+	userAgent   string
+	cookies     map[string]string
+	raw         json.RawMessage
+
+	// reporter and settings are set when the provider supports reporting
+	reporter IReporter
+	settings *Settings
 }
 
 func (a *CaptchaResponse) Solution() (solution, taskId string) {
 	return a.solution, a.taskId
 }
 
-var _ ICaptchaResponse = (*CaptchaResponse)(nil)
+func (a *CaptchaResponse) Provider() string {
+	return a.provider
+}
+
+func (a *CaptchaResponse) CaptchaType() CaptchaType {
+	return a.captchaType
+}
+
+func (a *CaptchaResponse) Cost() float64 {
+	return a.cost
+}
+
+func (a *CaptchaResponse) SubmittedAt() time.Time {
+	return a.submittedAt
+}
+
+func (a *CaptchaResponse) SolvedAt() time.Time {
+	return a.solvedAt
+}
+
+func (a *CaptchaResponse) Polls() int {
+	return a.polls
+}
+
+func (a *CaptchaResponse) SolveCount() int {
+	return a.solveCount
+}
+
+func (a *CaptchaResponse) IP() string {
+	return a.ip
+}
+
+func (a *CaptchaResponse) UserAgent() string {
+	return a.userAgent
+}
+
+func (a *CaptchaResponse) Cookies() map[string]string {
+	return a.cookies
+}
+
+func (a *CaptchaResponse) RawSolution() json.RawMessage {
+	return a.raw
+}
+
+func (a *CaptchaResponse) ReportGood(ctx context.Context) error {
+	if a.reporter == nil {
+		return reportUnsupportedError(a.provider, a.captchaType, a.taskId)
+	}
+
+	err := a.reporter.ReportCorrect(ctx, a.settings, a.captchaType, a.taskId)
+	a.settings.observeReported(a, true, err)
+	return err
+}
+
+func (a *CaptchaResponse) ReportBad(ctx context.Context) error {
+	if a.reporter == nil {
+		return reportUnsupportedError(a.provider, a.captchaType, a.taskId)
+	}
+
+	err := a.reporter.ReportIncorrect(ctx, a.settings, a.captchaType, a.taskId)
+	a.settings.observeReported(a, false, err)
+	return err
+}
+
+var (
+	_ IDetailedCaptchaResponse = (*CaptchaResponse)(nil)
+	_ IReportableResponse      = (*CaptchaResponse)(nil)
+)
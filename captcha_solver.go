@@ -2,66 +2,300 @@ package anticaptcha
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// CaptchaSolver solves captchas with a provider. Its settings are immutable snapshots, the setters swap in
+// a changed copy, so a solver can be reconfigured while it is being used by other goroutines.
 type CaptchaSolver struct {
 	provider IProvider
-	settings *Settings
+
+	// mu serializes the setters, so that concurrent changes aren't lost
+	mu       sync.Mutex
+	settings atomic.Pointer[Settings]
+}
+
+func NewCaptchaSolver(provider IProvider, opts ...Option) *CaptchaSolver {
+	c := &CaptchaSolver{provider: provider}
+	c.settings.Store(NewSettings().with(opts))
+	return c
+}
+
+// snapshot returns the current settings with the options of a single call applied.
+func (c *CaptchaSolver) snapshot(opts []Option) *Settings {
+	return c.settings.Load().with(opts)
 }
 
-func NewCaptchaSolver(provider IProvider) *CaptchaSolver {
-	return &CaptchaSolver{
-		settings: NewSettings(),
-		provider: provider,
+// update replaces the settings by a copy with the options applied.
+func (c *CaptchaSolver) update(opts ...Option) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	settings := c.settings.Load().clone()
+	for _, opt := range opts {
+		opt(settings)
 	}
+
+	c.settings.Store(settings)
 }
 
-func (c *CaptchaSolver) SolveImageCaptcha(ctx context.Context, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
-	return c.provider.SolveImageCaptcha(ctx, c.settings, payload)
+func (c *CaptchaSolver) SolveImageCaptcha(ctx context.Context, payload *ImageCaptchaPayload, opts ...Option) (ICaptchaResponse, error) {
+	return c.provider.SolveImageCaptcha(ctx, c.snapshot(opts), payload)
 }
 
-func (c *CaptchaSolver) SolveRecaptchaV2(ctx context.Context, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
-	return c.provider.SolveRecaptchaV2(ctx, c.settings, payload)
+func (c *CaptchaSolver) SolveRecaptchaV2(ctx context.Context, payload *RecaptchaV2Payload, opts ...Option) (ICaptchaResponse, error) {
+	return c.provider.SolveRecaptchaV2(ctx, c.snapshot(opts), payload)
 }
 
-func (c *CaptchaSolver) SolveRecaptchaV3(ctx context.Context, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
-	return c.provider.SolveRecaptchaV3(ctx, c.settings, payload)
+func (c *CaptchaSolver) SolveRecaptchaV3(ctx context.Context, payload *RecaptchaV3Payload, opts ...Option) (ICaptchaResponse, error) {
+	return c.provider.SolveRecaptchaV3(ctx, c.snapshot(opts), payload)
+}
+
+func (c *CaptchaSolver) SolveHCaptcha(ctx context.Context, payload *HCaptchaPayload, opts ...Option) (ICaptchaResponse, error) {
+	return c.provider.SolveHCaptcha(ctx, c.snapshot(opts), payload)
+}
+
+func (c *CaptchaSolver) SolveTurnstile(ctx context.Context, payload *TurnstilePayload, opts ...Option) (ICaptchaResponse, error) {
+	return c.provider.SolveTurnstile(ctx, c.snapshot(opts), payload)
+}
+
+func (c *CaptchaSolver) SolveCoordinates(ctx context.Context, payload *CoordinatesPayload, opts ...Option) (ICaptchaResponse, error) {
+	return c.provider.SolveCoordinates(ctx, c.snapshot(opts), payload)
+}
+
+func (c *CaptchaSolver) SolveCustom(ctx context.Context, payload *CustomPayload, opts ...Option) (ICaptchaResponse, error) {
+	return c.provider.SolveCustom(ctx, c.snapshot(opts), payload)
+}
+
+// Solve solves payload, which must be one of the payload types like *ImageCaptchaPayload,
+// with the matching Solve method of the provider.
+func (c *CaptchaSolver) Solve(ctx context.Context, payload any, opts ...Option) (ICaptchaResponse, error) {
+	switch payload := payload.(type) {
+	case *ImageCaptchaPayload:
+		return c.SolveImageCaptcha(ctx, payload, opts...)
+	case *RecaptchaV2Payload:
+		return c.SolveRecaptchaV2(ctx, payload, opts...)
+	case *RecaptchaV3Payload:
+		return c.SolveRecaptchaV3(ctx, payload, opts...)
+	case *HCaptchaPayload:
+		return c.SolveHCaptcha(ctx, payload, opts...)
+	case *TurnstilePayload:
+		return c.SolveTurnstile(ctx, payload, opts...)
+	case *CoordinatesPayload:
+		return c.SolveCoordinates(ctx, payload, opts...)
+	case *CustomPayload:
+		return c.SolveCustom(ctx, payload, opts...)
+	}
+
+	_, err := captchaTypeOf(providerName(c.provider), payload)
+	c.snapshot(opts).observeRefused(providerName(c.provider), "", err)
+	return nil, err
+}
+
+// Submit creates a task for payload, which must be one of the payload types like *ImageCaptchaPayload,
+// and returns as soon as the provider accepted it. Use Poll or Wait to get the solution.
+func (c *CaptchaSolver) Submit(ctx context.Context, payload any, opts ...Option) (*TaskHandle, error) {
+	provider, ok := c.provider.(IAsyncProvider)
+	if !ok {
+		return nil, &ProviderError{Provider: providerName(c.provider), Description: "submitting tasks is not supported", Err: ErrUnsupported}
+	}
+
+	return provider.CreateTask(ctx, c.snapshot(opts), payload)
+}
+
+// Poll fetches the result of the task once, it returns an error wrapping ErrNotReady while the task is being solved.
+func (c *CaptchaSolver) Poll(ctx context.Context, handle *TaskHandle, opts ...Option) (ICaptchaResponse, error) {
+	provider, err := c.asyncProvider(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.GetResult(ctx, c.snapshot(opts), handle)
+}
+
+// Wait polls the task until it is solved, using the same timing as the Solve methods. When polling until the
+// deadline, the max solve duration counts from the call to Wait, so that a task whose deadline was exceeded can
+// be waited on again.
+func (c *CaptchaSolver) Wait(ctx context.Context, handle *TaskHandle, opts ...Option) (ICaptchaResponse, error) {
+	provider, err := c.asyncProvider(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	waited := *handle
+	waited.budgetFrom = time.Now()
+	handle = &waited
+
+	// pollResult notifies the observers, GetResult must not do it a second time
+	settings := c.snapshot(opts)
+	quiet := settings.clone()
+	quiet.observers = nil
+
+	getResult := func(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) (*CaptchaResponse, error) {
+		resp, err := provider.GetResult(ctx, quiet, handle)
+		if errors.Is(err, ErrNotReady) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if result, ok := resp.(*CaptchaResponse); ok {
+			return result, nil
+		}
+
+		solution, _ := resp.Solution()
+		return &CaptchaResponse{solution: solution}, nil
+	}
+
+	result, err := pollResult(ctx, settings, c.provider, handle, getResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
-func (c *CaptchaSolver) SolveHCaptcha(ctx context.Context, payload *HCaptchaPayload) (ICaptchaResponse, error) {
-	return c.provider.SolveHCaptcha(ctx, c.settings, payload)
+// asyncProvider checks that the handle was created by the provider of the solver.
+func (c *CaptchaSolver) asyncProvider(handle *TaskHandle) (IAsyncProvider, error) {
+	name := providerName(c.provider)
+	provider, ok := c.provider.(IAsyncProvider)
+	if !ok {
+		return nil, &ProviderError{Provider: name, Description: "polling tasks is not supported", Err: ErrUnsupported}
+	}
+
+	if handle.Provider != name {
+		return nil, &ProviderError{
+			Provider:    name,
+			TaskId:      handle.TaskId,
+			CaptchaType: handle.CaptchaType,
+			Description: fmt.Sprintf("task was submitted to %v", handle.Provider),
+			Err:         ErrBadPayload,
+		}
+	}
+
+	return provider, nil
 }
 
-func (c *CaptchaSolver) SolveTurnstile(ctx context.Context, payload *TurnstilePayload) (ICaptchaResponse, error) {
-	return c.provider.SolveTurnstile(ctx, c.settings, payload)
+// Balance returns the balance of the account, providers that can't report it return an error wrapping ErrUnsupported.
+func (c *CaptchaSolver) Balance(ctx context.Context, opts ...Option) (float64, error) {
+	provider, ok := c.provider.(IBalanceProvider)
+	if !ok {
+		return 0, &ProviderError{Provider: providerName(c.provider), Description: "balance is not supported", Err: ErrUnsupported}
+	}
+
+	settings := c.snapshot(opts)
+	balance, err := provider.Balance(ctx, settings)
+	if err == nil {
+		settings.observeBalance(providerName(c.provider), balance)
+	}
+
+	return balance, err
 }
 
-func (c *CaptchaSolver) SolveCoordinates(ctx context.Context, payload *CoordinatesPayload) (ICaptchaResponse, error) {
-	return c.provider.SolveCoordinates(ctx, c.settings, payload)
+// ReportCorrect reports the solution of resp as correct to the provider that solved it.
+func (c *CaptchaSolver) ReportCorrect(ctx context.Context, resp ICaptchaResponse) error {
+	reportable, ok := resp.(IReportableResponse)
+	if !ok {
+		return reportUnsupportedError(providerName(c.provider), "", "")
+	}
+
+	return reportable.ReportGood(ctx)
 }
 
-func (c *CaptchaSolver) SolveCustom(ctx context.Context, payload *CustomPayload) (ICaptchaResponse, error) {
-	return c.provider.SolveCustom(ctx, c.settings, payload)
+// ReportIncorrect reports the solution of resp as incorrect to the provider that solved it.
+func (c *CaptchaSolver) ReportIncorrect(ctx context.Context, resp ICaptchaResponse) error {
+	reportable, ok := resp.(IReportableResponse)
+	if !ok {
+		return reportUnsupportedError(providerName(c.provider), "", "")
+	}
+
+	return reportable.ReportBad(ctx)
 }
 
 // SetClient will set the client that is used when interacting with APIs of providers.
 func (c *CaptchaSolver) SetClient(client *http.Client) {
-	c.settings.client = client
+	c.update(WithClient(client))
 }
 
 // SetInitialWaitTime sets the time that is being waited after submitting a task to a provider before polling
 func (c *CaptchaSolver) SetInitialWaitTime(waitTime time.Duration) {
-	c.settings.initialWaitTime = waitTime
+	c.update(WithInitialWaitTime(waitTime))
 }
 
 // SetPollInterval sets the time that is being waited in between result polls
 func (c *CaptchaSolver) SetPollInterval(interval time.Duration) {
-	c.settings.pollInterval = interval
+	c.update(WithPollInterval(interval))
+}
+
+// SetPollStrategy sets the strategy that decides when results are polled, see NewFixedPollStrategy,
+// NewBackoffPollStrategy and NewAdaptivePollStrategy
+func (c *CaptchaSolver) SetPollStrategy(strategy PollStrategy) {
+	c.update(WithPollStrategy(strategy))
+}
+
+// SetPollUntilDeadline makes polling run until the context deadline or the max solve duration of the captcha type
+// instead of stopping after the maximum amount of polling. The errors returned when the deadline is exceeded
+// carry the task id, see ProviderError.Handle.
+func (c *CaptchaSolver) SetPollUntilDeadline(enabled bool) {
+	c.update(WithPollUntilDeadline(enabled))
+}
+
+// SetMaxSolveDuration limits how long tasks of the captcha type are polled for when polling until the deadline
+func (c *CaptchaSolver) SetMaxSolveDuration(captchaType CaptchaType, duration time.Duration) {
+	c.update(WithMaxSolveDuration(captchaType, duration))
+}
+
+// SetTimingProfile overrides the initial wait, poll interval and max duration of the captcha type, zero fields keep
+// their current value
+func (c *CaptchaSolver) SetTimingProfile(captchaType CaptchaType, profile TimingProfile) {
+	c.update(WithTimingProfile(captchaType, profile))
+}
+
+// SetProviderTimingProfile overrides the timing of the captcha type when solved by the named provider
+func (c *CaptchaSolver) SetProviderTimingProfile(provider string, captchaType CaptchaType, profile TimingProfile) {
+	c.update(WithProviderTimingProfile(provider, captchaType, profile))
+}
+
+// SetCallback makes providers call back the receiver when a task is solved, the result is polled when no callback
+// arrived within grace of submitting the task
+func (c *CaptchaSolver) SetCallback(receiver CallbackReceiver, grace time.Duration) {
+	c.update(WithCallback(receiver, grace))
+}
+
+// SetRetryPolicy sets how requests that failed because of the network or an overloaded provider are retried
+func (c *CaptchaSolver) SetRetryPolicy(policy RetryPolicy) {
+	c.update(WithRetryPolicy(policy))
+}
+
+// SetRateLimiter makes requests wait on the limiter, it can be shared by solvers that use the same api key
+func (c *CaptchaSolver) SetRateLimiter(limiter *RateLimiter) {
+	c.update(WithRateLimiter(limiter))
+}
+
+// AddObserver registers an observer that is notified about every task solved with the solver
+func (c *CaptchaSolver) AddObserver(observer Observer) {
+	c.update(WithObserver(observer))
+}
+
+// SetLogger sets the logger requests and responses are logged to at debug level, api keys and images are redacted
+func (c *CaptchaSolver) SetLogger(logger *slog.Logger) {
+	c.update(WithLogger(logger))
+}
+
+// SetWireDump adds the redacted request and the response bodies to the logs, to debug incompatible providers
+func (c *CaptchaSolver) SetWireDump(enabled bool) {
+	c.update(WithWireDump(enabled))
 }
 
 // SetMaxRetries sets the maximum amount of polling
 func (c *CaptchaSolver) SetMaxRetries(maxRetries int) {
-	c.settings.maxRetries = maxRetries
+	c.update(WithMaxRetries(maxRetries))
 }
@@ -0,0 +1,38 @@
+package anticaptcha
+
+import "fmt"
+
+// CaptchaType identifies which kind of captcha a task is solving, it matches the IProvider method that was called.
+type CaptchaType string
+
+const (
+	CaptchaTypeImage       CaptchaType = "image"
+	CaptchaTypeRecaptchaV2 CaptchaType = "recaptcha_v2"
+	CaptchaTypeRecaptchaV3 CaptchaType = "recaptcha_v3"
+	CaptchaTypeHCaptcha    CaptchaType = "hcaptcha"
+	CaptchaTypeTurnstile   CaptchaType = "turnstile"
+	CaptchaTypeCoordinates CaptchaType = "coordinates"
+	CaptchaTypeCustom      CaptchaType = "custom"
+)
+
+// captchaTypeOf returns the captcha type that belongs to one of the payload types.
+func captchaTypeOf(provider string, payload any) (CaptchaType, error) {
+	switch payload.(type) {
+	case *ImageCaptchaPayload:
+		return CaptchaTypeImage, nil
+	case *RecaptchaV2Payload:
+		return CaptchaTypeRecaptchaV2, nil
+	case *RecaptchaV3Payload:
+		return CaptchaTypeRecaptchaV3, nil
+	case *HCaptchaPayload:
+		return CaptchaTypeHCaptcha, nil
+	case *TurnstilePayload:
+		return CaptchaTypeTurnstile, nil
+	case *CoordinatesPayload:
+		return CaptchaTypeCoordinates, nil
+	case *CustomPayload:
+		return CaptchaTypeCustom, nil
+	}
+
+	return "", &ProviderError{Provider: provider, Description: fmt.Sprintf("unknown payload type %T", payload), Err: ErrBadPayload}
+}
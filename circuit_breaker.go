@@ -0,0 +1,358 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by CircuitBreakerProvider while the circuit of the provider is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState int
+
+const (
+	// CircuitClosed lets every task through
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen fails every task without calling the provider
+	CircuitOpen
+
+	// CircuitHalfOpen lets a limited amount of probe tasks through to decide whether to close the circuit
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// CircuitStateChange is passed to CircuitBreakerConfig.OnStateChange on every transition.
+type CircuitStateChange struct {
+	Provider string
+
+	// CaptchaType is only set when CircuitBreakerConfig.PerCaptchaType is enabled
+	CaptchaType CaptchaType
+
+	From, To CircuitState
+}
+
+// CircuitBreakerConfig configures a CircuitBreakerProvider, zero values are replaced by defaults.
+type CircuitBreakerConfig struct {
+	// ConsecutiveFailures opens the circuit after this many failures in a row, defaults to 5
+	ConsecutiveFailures int
+
+	// ErrorRate opens the circuit when the share of failed tasks within Window reaches it, 0 disables it
+	ErrorRate float64
+
+	// Window is the duration ErrorRate is measured over, defaults to 1 minute
+	Window time.Duration
+
+	// MinRequests is the amount of tasks needed within Window before ErrorRate is checked, defaults to 10
+	MinRequests int
+
+	// OpenTimeout is the time the circuit stays open before probes are let through, defaults to 30 seconds
+	OpenTimeout time.Duration
+
+	// HalfOpenProbes is the amount of successful probes needed to close the circuit again, defaults to 1
+	HalfOpenProbes int
+
+	// PerCaptchaType keeps a separate circuit for every captcha type
+	PerCaptchaType bool
+
+	// OnStateChange is called whenever a circuit changes state
+	OnStateChange func(change CircuitStateChange)
+}
+
+// CircuitBreakerProvider stops sending tasks to a provider that keeps failing. Errors that are caused by the
+// task rather than the provider, like a bad payload, an unsupported type or an unsolvable captcha, and
+// cancellation by the caller don't count as failures.
+type CircuitBreakerProvider struct {
+	provider IProvider
+	config   CircuitBreakerConfig
+
+	mu       sync.Mutex
+	circuits map[CaptchaType]*circuit
+	changes  []CircuitStateChange
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int
+	probesOk int
+	outcomes []circuitOutcome
+}
+
+type circuitOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+func NewCircuitBreakerProvider(provider IProvider, config CircuitBreakerConfig) *CircuitBreakerProvider {
+	if config.ConsecutiveFailures <= 0 {
+		config.ConsecutiveFailures = 5
+	}
+
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+
+	if config.MinRequests <= 0 {
+		config.MinRequests = 10
+	}
+
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = 1
+	}
+
+	return &CircuitBreakerProvider{
+		provider: provider,
+		config:   config,
+		circuits: map[CaptchaType]*circuit{},
+	}
+}
+
+func (c *CircuitBreakerProvider) Name() string {
+	return providerName(c.provider)
+}
+
+// State returns the state of the circuit for the captcha type, the type is ignored unless PerCaptchaType is enabled
+func (c *CircuitBreakerProvider) State(captchaType CaptchaType) CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.circuit(captchaType).state
+}
+
+// Balance forwards to the wrapped provider, it is not affected by the state of the circuit
+func (c *CircuitBreakerProvider) Balance(ctx context.Context, settings *Settings) (float64, error) {
+	provider, ok := c.provider.(IBalanceProvider)
+	if !ok {
+		return 0, &ProviderError{Provider: c.Name(), Description: "balance is not supported", Err: ErrUnsupported}
+	}
+
+	return provider.Balance(ctx, settings)
+}
+
+func (c *CircuitBreakerProvider) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	return c.solve(ctx, settings, CaptchaTypeImage, func() (ICaptchaResponse, error) {
+		return c.provider.SolveImageCaptcha(ctx, settings, payload)
+	})
+}
+
+func (c *CircuitBreakerProvider) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	return c.solve(ctx, settings, CaptchaTypeRecaptchaV2, func() (ICaptchaResponse, error) {
+		return c.provider.SolveRecaptchaV2(ctx, settings, payload)
+	})
+}
+
+func (c *CircuitBreakerProvider) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	return c.solve(ctx, settings, CaptchaTypeRecaptchaV3, func() (ICaptchaResponse, error) {
+		return c.provider.SolveRecaptchaV3(ctx, settings, payload)
+	})
+}
+
+func (c *CircuitBreakerProvider) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	return c.solve(ctx, settings, CaptchaTypeHCaptcha, func() (ICaptchaResponse, error) {
+		return c.provider.SolveHCaptcha(ctx, settings, payload)
+	})
+}
+
+func (c *CircuitBreakerProvider) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	return c.solve(ctx, settings, CaptchaTypeTurnstile, func() (ICaptchaResponse, error) {
+		return c.provider.SolveTurnstile(ctx, settings, payload)
+	})
+}
+
+func (c *CircuitBreakerProvider) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return c.solve(ctx, settings, CaptchaTypeCoordinates, func() (ICaptchaResponse, error) {
+		return c.provider.SolveCoordinates(ctx, settings, payload)
+	})
+}
+
+func (c *CircuitBreakerProvider) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return c.solve(ctx, settings, CaptchaTypeCustom, func() (ICaptchaResponse, error) {
+		return c.provider.SolveCustom(ctx, settings, payload)
+	})
+}
+
+func (c *CircuitBreakerProvider) solve(ctx context.Context, settings *Settings, captchaType CaptchaType, solve func() (ICaptchaResponse, error)) (ICaptchaResponse, error) {
+	if !c.allow(captchaType) {
+		err := &ProviderError{
+			Provider:    c.Name(),
+			CaptchaType: captchaType,
+			Err:         ErrCircuitOpen,
+		}
+		settings.observeRefused(c.Name(), captchaType, err)
+		return nil, err
+	}
+
+	resp, err := solve()
+	switch {
+	case err == nil:
+		c.record(captchaType, false)
+	case ctx.Err() != nil, errors.Is(err, ErrBadPayload), errors.Is(err, ErrUnsupported), errors.Is(err, ErrUnsolvable):
+		// not the fault of the provider, but a probe slot has to be given back
+		c.release(captchaType)
+	default:
+		c.record(captchaType, true)
+	}
+
+	return resp, err
+}
+
+// circuit returns the circuit for the captcha type, c.mu must be held.
+func (c *CircuitBreakerProvider) circuit(captchaType CaptchaType) *circuit {
+	if !c.config.PerCaptchaType {
+		captchaType = ""
+	}
+
+	cb, ok := c.circuits[captchaType]
+	if !ok {
+		cb = &circuit{}
+		c.circuits[captchaType] = cb
+	}
+
+	return cb
+}
+
+func (c *CircuitBreakerProvider) allow(captchaType CaptchaType) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	cb := c.circuit(captchaType)
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < c.config.OpenTimeout {
+			return false
+		}
+
+		c.transition(captchaType, cb, CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if cb.probes >= c.config.HalfOpenProbes {
+			return false
+		}
+
+		cb.probes++
+	}
+
+	return true
+}
+
+func (c *CircuitBreakerProvider) release(captchaType CaptchaType) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if cb := c.circuit(captchaType); cb.state == CircuitHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+}
+
+func (c *CircuitBreakerProvider) record(captchaType CaptchaType, failed bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	cb := c.circuit(captchaType)
+	now := time.Now()
+
+	switch cb.state {
+	case CircuitHalfOpen:
+		if failed {
+			c.transition(captchaType, cb, CircuitOpen)
+			return
+		}
+
+		cb.probesOk++
+		if cb.probesOk >= c.config.HalfOpenProbes {
+			c.transition(captchaType, cb, CircuitClosed)
+		}
+		return
+	case CircuitOpen:
+		// a task that was let through before the circuit opened
+		return
+	}
+
+	cb.outcomes = append(cb.outcomes, circuitOutcome{at: now, failed: failed})
+	for len(cb.outcomes) > 0 && now.Sub(cb.outcomes[0].at) > c.config.Window {
+		cb.outcomes = cb.outcomes[1:]
+	}
+
+	if !failed {
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.failures >= c.config.ConsecutiveFailures || c.errorRateExceeded(cb) {
+		c.transition(captchaType, cb, CircuitOpen)
+	}
+}
+
+func (c *CircuitBreakerProvider) errorRateExceeded(cb *circuit) bool {
+	if c.config.ErrorRate <= 0 || len(cb.outcomes) < c.config.MinRequests {
+		return false
+	}
+
+	failed := 0
+	for _, outcome := range cb.outcomes {
+		if outcome.failed {
+			failed++
+		}
+	}
+
+	return float64(failed)/float64(len(cb.outcomes)) >= c.config.ErrorRate
+}
+
+// transition moves the circuit to the new state and resets its counters, c.mu must be held.
+func (c *CircuitBreakerProvider) transition(captchaType CaptchaType, cb *circuit, to CircuitState) {
+	from := cb.state
+	cb.state = to
+	cb.failures = 0
+	cb.probes = 0
+	cb.probesOk = 0
+	cb.outcomes = nil
+	if to == CircuitOpen {
+		cb.openedAt = time.Now()
+	}
+
+	if !c.config.PerCaptchaType {
+		captchaType = ""
+	}
+	c.changes = append(c.changes, CircuitStateChange{Provider: c.Name(), CaptchaType: captchaType, From: from, To: to})
+}
+
+// unlock releases c.mu and only then reports the transitions, so that OnStateChange may call back into the provider.
+func (c *CircuitBreakerProvider) unlock() {
+	changes := c.changes
+	c.changes = nil
+	c.mu.Unlock()
+
+	if c.config.OnStateChange == nil {
+		return
+	}
+
+	for _, change := range changes {
+		c.config.OnStateChange(change)
+	}
+}
+
+var (
+	_ IProvider        = (*CircuitBreakerProvider)(nil)
+	_ IBalanceProvider = (*CircuitBreakerProvider)(nil)
+)
@@ -0,0 +1,71 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerProvider(t *testing.T) {
+	provider := &stubProvider{name: "flaky", err: errors.New("connection refused")}
+
+	var changes []CircuitStateChange
+	breaker := NewCircuitBreakerProvider(provider, CircuitBreakerConfig{
+		ConsecutiveFailures: 2,
+		OpenTimeout:         20 * time.Millisecond,
+		OnStateChange: func(change CircuitStateChange) {
+			changes = append(changes, change)
+		},
+	})
+	cs := NewCaptchaSolver(breaker)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("circuit opened too early")
+		}
+	}
+
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	if provider.calls != 2 {
+		t.Errorf("provider should not be called while the circuit is open, got %d calls", provider.calls)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	provider.err = nil
+	provider.solution = "answer"
+
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); err != nil {
+		t.Fatalf("probe should be let through, got %v", err)
+	}
+
+	if state := breaker.State(CaptchaTypeImage); state != CircuitClosed {
+		t.Errorf("expected the circuit to be closed after a successful probe, got %v", state)
+	}
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d transitions, got %+v", len(want), changes)
+	}
+
+	for i, change := range changes {
+		if change.To != want[i] || change.Provider != "flaky" {
+			t.Errorf("transition %d: got %+v, want state %v", i, change, want[i])
+		}
+	}
+}
+
+func TestCircuitBreakerIgnoresBadPayload(t *testing.T) {
+	provider := &stubProvider{name: "strict", err: &ProviderError{Err: ErrBadPayload}}
+	cs := NewCaptchaSolver(NewCircuitBreakerProvider(provider, CircuitBreakerConfig{ConsecutiveFailures: 1}))
+
+	for i := 0; i < 3; i++ {
+		if _, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{}); !errors.Is(err, ErrBadPayload) {
+			t.Fatalf("expected ErrBadPayload, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package anticaptcha
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Sentinel errors that can be matched with errors.Is against any error returned by a provider.
+var (
+	// ErrZeroBalance is returned when the account has no funds left
+	ErrZeroBalance = errors.New("zero balance")
+
+	// ErrUnsolvable is returned when the workers of the provider were unable to solve the captcha
+	ErrUnsolvable = errors.New("captcha unsolvable")
+
+	// ErrInvalidKey is returned when the api key is missing, invalid or not allowed to be used
+	ErrInvalidKey = errors.New("invalid api key")
+
+	// ErrNoSlot is returned when the provider has no free workers or the account hit its concurrency limit
+	ErrNoSlot = errors.New("no slot available")
+
+	// ErrUnsupported is returned when the provider does not support the captcha type or operation
+	ErrUnsupported = errors.New("unsupported")
+
+	// ErrTimeout is returned when the task was not solved within the allowed amount of polls
+	ErrTimeout = errors.New("max tries exceeded")
+
+	// ErrBadPayload is returned when the provider rejected the task because of its parameters
+	ErrBadPayload = errors.New("bad payload")
+)
+
+// ProviderError is the error returned by providers whenever a task fails, it wraps one of the sentinel errors
+// when the code of the provider is known.
+type ProviderError struct {
+	// Provider is the name of the provider that returned the error
+	Provider string
+
+	// TaskId is the id of the task, empty if the task was never created
+	TaskId string
+
+	// CaptchaType is the type of captcha that was being solved
+	CaptchaType CaptchaType
+
+	// SubmittedAt is the time the task was created, zero if the task was never created
+	SubmittedAt time.Time
+
+	// Code is the raw error code returned by the provider, e.g. ERROR_ZERO_BALANCE
+	Code string
+
+	// Description is the human-readable description of the error, if the provider sent one
+	Description string
+
+	// Err is the sentinel error the code maps to, nil if the code is unknown
+	Err error
+}
+
+func (e *ProviderError) Error() string {
+	parts := make([]string, 0, 4)
+	if e.Provider != "" {
+		parts = append(parts, e.Provider)
+	}
+
+	if e.TaskId != "" {
+		parts = append(parts, "task "+e.TaskId)
+	}
+
+	if e.Code != "" {
+		parts = append(parts, e.Code)
+	}
+
+	switch {
+	case e.Description != "":
+		parts = append(parts, e.Description)
+	case e.Err != nil:
+		parts = append(parts, e.Err.Error())
+	}
+
+	return strings.Join(parts, ": ")
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// antiCaptchaErrors maps the error codes of AntiCaptcha and compatible APIs (CapMonster Cloud, CapSolver, XEVil)
+// to sentinel errors. ErrUnsupported is kept for codes about the provider, codes about a single image or proxy are
+// ErrBadPayload, so that other providers aren't tried with the same payload.
+var antiCaptchaErrors = map[string]error{
+	"ERROR_KEY_DOES_NOT_EXIST":                   ErrInvalidKey,
+	"ERROR_KEY_DENIED_ACCESS":                    ErrInvalidKey,
+	"ERROR_IP_NOT_ALLOWED":                       ErrInvalidKey,
+	"ERROR_IP_BLOCKED":                           ErrInvalidKey,
+	"ERROR_ACCOUNT_SUSPENDED":                    ErrInvalidKey,
+	"ERROR_ZERO_BALANCE":                         ErrZeroBalance,
+	"ERROR_NO_SLOT_AVAILABLE":                    ErrNoSlot,
+	"ERROR_SERVICE_UNAVALIABLE":                  ErrNoSlot,
+	"ERROR_TOO_MUCH_REQUESTS":                    ErrNoSlot,
+	"ERROR_CAPTCHA_UNSOLVABLE":                   ErrUnsolvable,
+	"ERROR_BAD_DUPLICATES":                       ErrUnsolvable,
+	"ERROR_RECAPTCHA_TIMEOUT":                    ErrUnsolvable,
+	"ERROR_MAXIMUM_TIME_EXCEED":                  ErrTimeout,
+	"ERROR_TASK_NOT_SUPPORTED":                   ErrUnsupported,
+	"ERROR_NO_SUCH_METHOD":                       ErrUnsupported,
+	"ERROR_IMAGE_TYPE_NOT_SUPPORTED":             ErrBadPayload,
+	"ERROR_PROXY_HAS_NO_IMAGE_SUPPORT":           ErrBadPayload,
+	"ERROR_ZERO_CAPTCHA_FILESIZE":                ErrBadPayload,
+	"ERROR_TOO_BIG_CAPTCHA_FILESIZE":             ErrBadPayload,
+	"ERROR_INCORRECT_SESSION_DATA":               ErrBadPayload,
+	"ERROR_INVALID_TASK_DATA":                    ErrBadPayload,
+	"ERROR_RECAPTCHA_INVALID_SITEKEY":            ErrBadPayload,
+	"ERROR_RECAPTCHA_INVALID_DOMAIN":             ErrBadPayload,
+	"ERROR_RECAPTCHA_OLD_BROWSER":                ErrBadPayload,
+	"ERROR_WRONG_CAPTCHA_ID":                     ErrBadPayload,
+	"ERROR_NO_SUCH_CAPCHA_ID":                    ErrBadPayload,
+	"ERROR_TASKID_INVALID":                       ErrBadPayload,
+	"ERROR_TASK_ABSENT":                          ErrBadPayload,
+	"ERROR_DOMAIN_NOT_ALLOWED":                   ErrBadPayload,
+	"ERROR_TEMPLATE_NOT_FOUND":                   ErrBadPayload,
+	"ERROR_PROXY_CONNECT_REFUSED":                ErrBadPayload,
+	"ERROR_PROXY_CONNECT_TIMEOUT":                ErrBadPayload,
+	"ERROR_PROXY_READ_TIMEOUT":                   ErrBadPayload,
+	"ERROR_PROXY_BANNED":                         ErrBadPayload,
+	"ERROR_PROXY_TRANSPARENT":                    ErrBadPayload,
+	"ERROR_PROXY_INCOMPATIBLE_HTTP_VERSION":      ErrBadPayload,
+	"ERROR_PROXY_NOT_AUTHORISED":                 ErrBadPayload,
+	"ERROR_FACTORY_SERVER_API_CONNECTION_FAILED": ErrNoSlot,
+}
+
+// twoCaptchaErrors maps the error codes of 2Captcha and compatible in.php/res.php APIs (WhiteCaptcha, CapGuru)
+// to sentinel errors.
+var twoCaptchaErrors = map[string]error{
+	"ERROR_WRONG_USER_KEY":           ErrInvalidKey,
+	"ERROR_KEY_DOES_NOT_EXIST":       ErrInvalidKey,
+	"ERROR_IP_NOT_ALLOWED":           ErrInvalidKey,
+	"IP_BANNED":                      ErrInvalidKey,
+	"ERROR_IP_ADDRES":                ErrInvalidKey,
+	"ERROR_ZERO_BALANCE":             ErrZeroBalance,
+	"ERROR_NO_SLOT_AVAILABLE":        ErrNoSlot,
+	"MAX_USER_TURN":                  ErrNoSlot,
+	"ERROR_CAPTCHA_UNSOLVABLE":       ErrUnsolvable,
+	"ERROR_BAD_DUPLICATES":           ErrUnsolvable,
+	"ERROR_IMAGE_TYPE_NOT_SUPPORTED": ErrBadPayload,
+	"ERROR_WRONG_FILE_EXTENSION":     ErrBadPayload,
+	"ERROR_METHOD_CALL":              ErrUnsupported,
+	"ERROR_ZERO_CAPTCHA_FILESIZE":    ErrBadPayload,
+	"ERROR_TOO_BIG_CAPTCHA_FILESIZE": ErrBadPayload,
+	"ERROR_UPLOAD":                   ErrBadPayload,
+	"ERROR_CAPTCHAIMAGE_BLOCKED":     ErrBadPayload,
+	"TOO_MANY_BAD_IMAGES":            ErrBadPayload,
+	"ERROR_BAD_PARAMETERS":           ErrBadPayload,
+	"ERROR_BAD_PROXY":                ErrBadPayload,
+	"ERROR_PROXY_FORMAT":             ErrBadPayload,
+	"ERROR_PROXY_CONNECTION_FAILED":  ErrBadPayload,
+	"ERROR_GOOGLEKEY":                ErrBadPayload,
+	"ERROR_WRONG_GOOGLEKEY":          ErrBadPayload,
+	"ERROR_BAD_TOKEN_OR_PAGEURL":     ErrBadPayload,
+	"ERROR_PAGEURL":                  ErrBadPayload,
+	"ERROR_SITEKEY":                  ErrBadPayload,
+	"ERROR_WRONG_ID_FORMAT":          ErrBadPayload,
+	"ERROR_WRONG_CAPTCHA_ID":         ErrBadPayload,
+	"ERROR_EMPTY_ACTION":             ErrBadPayload,
+	"ERROR_TOKEN_EXPIRED":            ErrBadPayload,
+}
+
+// newProviderError builds a ProviderError, looking up code in table to find the matching sentinel error.
+func newProviderError(table map[string]error, provider string, captchaType CaptchaType, taskId, code, description string) *ProviderError {
+	return &ProviderError{
+		Provider:    provider,
+		TaskId:      taskId,
+		CaptchaType: captchaType,
+		Code:        code,
+		Description: description,
+		Err:         table[code],
+	}
+}
+
+func unsupportedError(provider string, captchaType CaptchaType) *ProviderError {
+	return &ProviderError{
+		Provider:    provider,
+		CaptchaType: captchaType,
+		Description: fmt.Sprintf("%v captcha is not supported", captchaType),
+		Err:         ErrUnsupported,
+	}
+}
+
+func timeoutError(provider string, captchaType CaptchaType, taskId string) *ProviderError {
+	return &ProviderError{
+		Provider:    provider,
+		TaskId:      taskId,
+		CaptchaType: captchaType,
+		Err:         ErrTimeout,
+	}
+}
+
+// providerName returns the name of the provider if it has one, otherwise its type name is used.
+func providerName(provider IProvider) string {
+	if named, ok := provider.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+
+	return fmt.Sprintf("%T", provider)
+}
+
+// nameFromBaseUrl is used by the custom constructors so that providers pointing at different hosts can be told apart.
+func nameFromBaseUrl(baseUrl, fallback string) string {
+	u, err := url.Parse(baseUrl)
+	if err != nil || u.Hostname() == "" {
+		return fallback
+	}
+
+	return u.Hostname()
+}
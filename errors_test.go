@@ -0,0 +1,60 @@
+package anticaptcha
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestProviderErrorIs(t *testing.T) {
+	tests := []struct {
+		table map[string]error
+		code  string
+		want  error
+	}{
+		{antiCaptchaErrors, "ERROR_ZERO_BALANCE", ErrZeroBalance},
+		{antiCaptchaErrors, "ERROR_KEY_DOES_NOT_EXIST", ErrInvalidKey},
+		{antiCaptchaErrors, "ERROR_NO_SLOT_AVAILABLE", ErrNoSlot},
+		{antiCaptchaErrors, "ERROR_CAPTCHA_UNSOLVABLE", ErrUnsolvable},
+		{twoCaptchaErrors, "ERROR_WRONG_USER_KEY", ErrInvalidKey},
+		{twoCaptchaErrors, "ERROR_ZERO_BALANCE", ErrZeroBalance},
+		{twoCaptchaErrors, "ERROR_BAD_PARAMETERS", ErrBadPayload},
+		{antiCaptchaErrors, "ERROR_TASK_NOT_SUPPORTED", ErrUnsupported},
+		{twoCaptchaErrors, "ERROR_METHOD_CALL", ErrUnsupported},
+		// a single image or proxy was rejected, not the captcha type
+		{antiCaptchaErrors, "ERROR_IMAGE_TYPE_NOT_SUPPORTED", ErrBadPayload},
+		{antiCaptchaErrors, "ERROR_PROXY_HAS_NO_IMAGE_SUPPORT", ErrBadPayload},
+		{twoCaptchaErrors, "ERROR_IMAGE_TYPE_NOT_SUPPORTED", ErrBadPayload},
+		{twoCaptchaErrors, "ERROR_WRONG_FILE_EXTENSION", ErrBadPayload},
+		{twoCaptchaErrors, "ERROR_PROXY_FORMAT", ErrBadPayload},
+		{twoCaptchaErrors, "ERROR_IP_ADDRES", ErrInvalidKey},
+		{antiCaptchaErrors, "ERROR_TOO_MUCH_REQUESTS", ErrNoSlot},
+	}
+
+	for _, tt := range tests {
+		err := error(newProviderError(tt.table, "test", CaptchaTypeImage, "1", tt.code, ""))
+		if !errors.Is(fmt.Errorf("wrapped: %w", err), tt.want) {
+			t.Errorf("%v: expected errors.Is(%v)", tt.code, tt.want)
+		}
+
+		var providerErr *ProviderError
+		if !errors.As(err, &providerErr) || providerErr.Code != tt.code {
+			t.Errorf("%v: expected errors.As to expose the code", tt.code)
+		}
+	}
+
+	if err := newProviderError(twoCaptchaErrors, "test", CaptchaTypeImage, "", "ERROR_SOMETHING_NEW", ""); err.Err != nil {
+		t.Errorf("unknown code should not map to a sentinel, got %v", err.Err)
+	}
+}
+
+func TestProviderErrorMessage(t *testing.T) {
+	err := newProviderError(antiCaptchaErrors, "anticaptcha", CaptchaTypeImage, "42", "ERROR_ZERO_BALANCE", "Account has zero balance")
+	if got, want := err.Error(), "anticaptcha: task 42: ERROR_ZERO_BALANCE: Account has zero balance"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got, want := timeoutError("2captcha", CaptchaTypeRecaptchaV2, "7").Error(), "2captcha: task 7: max tries exceeded"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
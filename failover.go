@@ -0,0 +1,117 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+)
+
+// FailoverProvider tries its providers in order and returns the first solution. It only moves on to the next
+// provider when the error is one another provider could do better at, like an unsupported captcha type,
+// an empty balance or an open circuit; other errors, such as a bad payload, are returned straight away.
+type FailoverProvider struct {
+	providers []IProvider
+}
+
+func NewFailoverProvider(providers ...IProvider) *FailoverProvider {
+	return &FailoverProvider{
+		providers: providers,
+	}
+}
+
+func (f *FailoverProvider) Name() string {
+	return "failover"
+}
+
+func (f *FailoverProvider) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveImageCaptcha(ctx, settings, payload)
+	})
+}
+
+func (f *FailoverProvider) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	return f.solve(ctx, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveRecaptchaV2(ctx, settings, payload)
+	})
+}
+
+func (f *FailoverProvider) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	return f.solve(ctx, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveRecaptchaV3(ctx, settings, payload)
+	})
+}
+
+func (f *FailoverProvider) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveHCaptcha(ctx, settings, payload)
+	})
+}
+
+func (f *FailoverProvider) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveTurnstile(ctx, settings, payload)
+	})
+}
+
+func (f *FailoverProvider) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveCoordinates(ctx, settings, payload)
+	})
+}
+
+func (f *FailoverProvider) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, func(provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveCustom(ctx, settings, payload)
+	})
+}
+
+// solve calls solve for every provider until one succeeds, the returned error joins the errors of all providers
+// that were tried.
+func (f *FailoverProvider) solve(ctx context.Context, solve func(provider IProvider) (ICaptchaResponse, error)) (ICaptchaResponse, error) {
+	if len(f.providers) == 0 {
+		return nil, errors.New("failover: no providers")
+	}
+
+	var errs []error
+	for _, provider := range f.providers {
+		// the deadline of the caller applies to the whole chain, not to each provider
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		resp, err := solve(provider)
+		if err == nil {
+			return withProvider(resp, providerName(provider)), nil
+		}
+
+		errs = append(errs, err)
+		if !shouldFailover(err) {
+			break
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// shouldFailover reports whether the error is specific to the provider that returned it,
+// meaning that the same payload could still be solved by another provider.
+func shouldFailover(err error) bool {
+	return errors.Is(err, ErrUnsupported) ||
+		errors.Is(err, ErrZeroBalance) ||
+		errors.Is(err, ErrNoSlot) ||
+		errors.Is(err, ErrUnsolvable) ||
+		errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrUnavailable) ||
+		errors.Is(err, ErrCircuitOpen)
+}
+
+// withProvider records which provider answered on responses that don't already carry it.
+func withProvider(resp ICaptchaResponse, provider string) ICaptchaResponse {
+	if r, ok := resp.(*CaptchaResponse); ok && r.provider == "" {
+		r.provider = provider
+	}
+
+	return resp
+}
+
+var _ IProvider = (*FailoverProvider)(nil)
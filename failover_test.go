@@ -0,0 +1,114 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/packman80/anticaptcha/internal"
+)
+
+// stubProvider answers every captcha type with the same solution or error.
+type stubProvider struct {
+	name     string
+	solution string
+	err      error
+	latency  time.Duration
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *stubProvider) Name() string {
+	return s.name
+}
+
+func (s *stubProvider) solve(ctx context.Context) (ICaptchaResponse, error) {
+	s.mu.Lock()
+	s.calls++
+	s.mu.Unlock()
+
+	if err := internal.SleepWithContext(ctx, s.latency); err != nil {
+		return nil, err
+	}
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &CaptchaResponse{solution: s.solution, taskId: s.name}, nil
+}
+
+func (s *stubProvider) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	return s.solve(ctx)
+}
+
+func (s *stubProvider) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	return s.solve(ctx)
+}
+
+func (s *stubProvider) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	return s.solve(ctx)
+}
+
+func (s *stubProvider) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	return s.solve(ctx)
+}
+
+func (s *stubProvider) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	return s.solve(ctx)
+}
+
+func (s *stubProvider) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return s.solve(ctx)
+}
+
+func (s *stubProvider) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return s.solve(ctx)
+}
+
+func TestFailoverProvider(t *testing.T) {
+	primary := &stubProvider{name: "primary", err: &ProviderError{Err: ErrZeroBalance}}
+	backup := &stubProvider{name: "backup", solution: "answer"}
+
+	cs := NewCaptchaSolver(NewFailoverProvider(primary, backup))
+	resp, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, _ := resp.Solution(); solution != "answer" {
+		t.Errorf("unexpected solution %q", solution)
+	}
+
+	if provider := resp.(IDetailedCaptchaResponse).Provider(); provider != "backup" {
+		t.Errorf("expected backup to answer, got %q", provider)
+	}
+}
+
+func TestFailoverProviderStopsOnPermanentError(t *testing.T) {
+	primary := &stubProvider{name: "primary", err: &ProviderError{Err: ErrBadPayload}}
+	backup := &stubProvider{name: "backup", solution: "answer"}
+
+	cs := NewCaptchaSolver(NewFailoverProvider(primary, backup))
+	if _, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{}); !errors.Is(err, ErrBadPayload) {
+		t.Fatalf("expected ErrBadPayload, got %v", err)
+	}
+
+	if backup.calls != 0 {
+		t.Errorf("backup should not be called on a bad payload")
+	}
+}
+
+func TestFailoverProviderAllFailed(t *testing.T) {
+	primary := &stubProvider{name: "primary", err: &ProviderError{Err: ErrNoSlot}}
+	backup := &stubProvider{name: "backup", err: &ProviderError{Err: ErrUnsolvable}}
+
+	cs := NewCaptchaSolver(NewFailoverProvider(primary, backup))
+	_, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{})
+	if !errors.Is(err, ErrNoSlot) || !errors.Is(err, ErrUnsolvable) {
+		t.Fatalf("expected the errors of both providers, got %v", err)
+	}
+}
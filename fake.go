@@ -0,0 +1,255 @@
+package anticaptcha
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/packman80/anticaptcha/internal"
+)
+
+// FakeBehavior scripts how FakeProvider answers the tasks of a captcha type.
+type FakeBehavior struct {
+	// Solution is the answer to every task, unless Solve is set
+	Solution string
+
+	// Solve generates the answer from the payload of the task, e.g. *ImageCaptchaPayload. The error it returns
+	// fails the task as it is.
+	Solve func(payload any) (string, error)
+
+	// Latency is how long solving a task takes, a random duration of up to Jitter is added to it
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// ZeroBalanceRate, UnsolvableRate and TimeoutRate are the probabilities between 0 and 1 that a task fails with
+	// ErrZeroBalance, ErrUnsolvable or ErrTimeout, together they should be at most 1. Zero balance is reported when
+	// the task is created, the other errors after the latency.
+	ZeroBalanceRate float64
+	UnsolvableRate  float64
+	TimeoutRate     float64
+
+	// Cost is the cost reported for solved tasks
+	Cost float64
+}
+
+// FakeCall is a task solved by FakeProvider.
+type FakeCall struct {
+	CaptchaType CaptchaType
+	Payload     any
+	TaskId      string
+	Solution    string
+	Err         error
+	Start       time.Time
+	End         time.Time
+}
+
+// FakeProvider is an in-memory IProvider for tests. Every captcha type answers according to its FakeBehavior,
+// types without one are solved right away with a generated answer like "image-1". All calls are recorded.
+type FakeProvider struct {
+	name string
+
+	mu        sync.Mutex
+	behaviors map[CaptchaType]FakeBehavior
+	calls     []FakeCall
+	rand      *rand.Rand
+	lastId    int
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		name:      "fake",
+		behaviors: map[CaptchaType]FakeBehavior{},
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Name returns the name of the provider as used in errors, "fake".
+func (f *FakeProvider) Name() string {
+	return f.name
+}
+
+// Script sets how tasks of the captcha type are answered
+func (f *FakeProvider) Script(captchaType CaptchaType, behavior FakeBehavior) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.behaviors[captchaType] = behavior
+}
+
+// SetSeed seeds the random errors and jitter, so that a test sees the same outcomes on every run
+func (f *FakeProvider) SetSeed(seed int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rand = rand.New(rand.NewSource(seed))
+}
+
+// Calls returns the calls that were made so far, in the order they were made
+func (f *FakeProvider) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := make([]FakeCall, len(f.calls))
+	copy(calls, f.calls)
+	return calls
+}
+
+// CallsOf returns the calls that were made for the captcha type
+func (f *FakeProvider) CallsOf(captchaType CaptchaType) []FakeCall {
+	var calls []FakeCall
+	for _, call := range f.Calls() {
+		if call.CaptchaType == captchaType {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Reset forgets the recorded calls, the behaviors are kept
+func (f *FakeProvider) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = nil
+}
+
+func (f *FakeProvider) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, settings, CaptchaTypeImage, payload)
+}
+
+func (f *FakeProvider) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	return f.solve(ctx, settings, CaptchaTypeRecaptchaV2, payload)
+}
+
+func (f *FakeProvider) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	return f.solve(ctx, settings, CaptchaTypeRecaptchaV3, payload)
+}
+
+func (f *FakeProvider) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, settings, CaptchaTypeHCaptcha, payload)
+}
+
+func (f *FakeProvider) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, settings, CaptchaTypeTurnstile, payload)
+}
+
+func (f *FakeProvider) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, settings, CaptchaTypeCoordinates, payload)
+}
+
+func (f *FakeProvider) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return f.solve(ctx, settings, CaptchaTypeCustom, payload)
+}
+
+func (f *FakeProvider) solve(ctx context.Context, settings *Settings, captchaType CaptchaType, payload any) (ICaptchaResponse, error) {
+	call := FakeCall{CaptchaType: captchaType, Payload: payload, Start: time.Now()}
+	result, err := f.solveTask(ctx, settings, captchaType, payload, &call)
+	call.End = time.Now()
+	call.Err = err
+	if result != nil {
+		call.Solution = result.solution
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (f *FakeProvider) solveTask(ctx context.Context, settings *Settings, captchaType CaptchaType, payload any, call *FakeCall) (*CaptchaResponse, error) {
+	task := f.next(captchaType)
+
+	handle, err := submitTask(settings, f.name, captchaType, func() (string, error) {
+		if task.outcome == ErrZeroBalance {
+			return "", &ProviderError{Provider: f.name, CaptchaType: captchaType, Code: "ERROR_ZERO_BALANCE", Err: ErrZeroBalance}
+		}
+
+		return task.taskId, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	call.TaskId = handle.TaskId
+
+	result, err := f.answer(ctx, task, handle, payload)
+	settings.observePolled(handle, 1, result != nil, err)
+	if err != nil {
+		settings.observeFailed(handle, err)
+		return nil, err
+	}
+
+	completeResult(result, settings, f, handle, 1)
+	settings.observeSolved(handle, result)
+	return result, nil
+}
+
+// fakeTask is the drawn outcome of a task, which is nil when the task is solved
+type fakeTask struct {
+	behavior FakeBehavior
+	taskId   string
+	latency  time.Duration
+	outcome  error
+}
+
+// next draws the latency and outcome of a task.
+func (f *FakeProvider) next(captchaType CaptchaType) fakeTask {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastId++
+	behavior := f.behaviors[captchaType]
+	task := fakeTask{behavior: behavior, taskId: strconv.Itoa(f.lastId), latency: behavior.Latency}
+	if behavior.Jitter > 0 {
+		task.latency += time.Duration(f.rand.Int63n(int64(behavior.Jitter)))
+	}
+
+	switch r := f.rand.Float64(); {
+	case r < behavior.ZeroBalanceRate:
+		task.outcome = ErrZeroBalance
+	case r < behavior.ZeroBalanceRate+behavior.UnsolvableRate:
+		task.outcome = ErrUnsolvable
+	case r < behavior.ZeroBalanceRate+behavior.UnsolvableRate+behavior.TimeoutRate:
+		task.outcome = ErrTimeout
+	}
+
+	return task
+}
+
+// answer waits for the latency and returns the scripted solution or error.
+func (f *FakeProvider) answer(ctx context.Context, task fakeTask, handle *TaskHandle, payload any) (*CaptchaResponse, error) {
+	if err := internal.SleepWithContext(ctx, task.latency); err != nil {
+		return nil, err
+	}
+
+	behavior := task.behavior
+	switch task.outcome {
+	case ErrUnsolvable:
+		return nil, &ProviderError{Provider: f.name, TaskId: handle.TaskId, CaptchaType: handle.CaptchaType, Code: "ERROR_CAPTCHA_UNSOLVABLE", Err: ErrUnsolvable}
+	case ErrTimeout:
+		return nil, timeoutError(f.name, handle.CaptchaType, handle.TaskId)
+	}
+
+	solution := behavior.Solution
+	switch {
+	case behavior.Solve != nil:
+		var err error
+		if solution, err = behavior.Solve(payload); err != nil {
+			return nil, err
+		}
+	case solution == "":
+		solution = fmt.Sprintf("%v-%v", handle.CaptchaType, handle.TaskId)
+	}
+
+	return &CaptchaResponse{solution: solution, cost: behavior.Cost}, nil
+}
+
+var _ IProvider = (*FakeProvider)(nil)
@@ -0,0 +1,134 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFakeProvider(t *testing.T) {
+	fake := NewFakeProvider()
+	fake.Script(CaptchaTypeImage, FakeBehavior{Solution: "answer", Cost: 0.001})
+	fake.Script(CaptchaTypeCustom, FakeBehavior{Solve: func(payload any) (string, error) {
+		return payload.(*CustomPayload).Params["question"].(string) + "!", nil
+	}})
+
+	observer := &recordingObserver{}
+	cs := NewCaptchaSolver(fake, WithObserver(observer))
+	ctx := context.Background()
+
+	resp, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	detailed := resp.(IDetailedCaptchaResponse)
+	if solution, taskId := resp.Solution(); solution != "answer" || taskId != "1" || detailed.Cost() != 0.001 || detailed.Provider() != "fake" {
+		t.Errorf("unexpected response %q for task %q, cost %v", solution, taskId, detailed.Cost())
+	}
+
+	resp, err = cs.SolveCustom(ctx, &CustomPayload{Params: map[string]any{"question": "hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, _ := resp.Solution(); solution != "hello!" {
+		t.Errorf("expected the generated solution, got %q", solution)
+	}
+
+	resp, err = cs.SolveTurnstile(ctx, &TurnstilePayload{EndpointKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, _ := resp.Solution(); solution != "turnstile-3" {
+		t.Errorf("expected a solution for types without a behavior, got %q", solution)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 3 || calls[1].CaptchaType != CaptchaTypeCustom || calls[1].Solution != "hello!" || calls[1].TaskId != "2" {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+
+	if payload, ok := fake.CallsOf(CaptchaTypeImage)[0].Payload.(*ImageCaptchaPayload); !ok || payload.Base64String != "aW1hZ2U=" {
+		t.Errorf("expected the payload to be recorded, got %+v", fake.CallsOf(CaptchaTypeImage))
+	}
+
+	want := []string{"created 1", "polled 1 1 true", "solved 1 0.001 1"}
+	if len(observer.events) != 9 || fmt.Sprint(observer.events[:3]) != fmt.Sprint(want) {
+		t.Errorf("expected the observers to be notified, got %v", observer.events)
+	}
+
+	fake.Reset()
+	if len(fake.Calls()) != 0 {
+		t.Error("calls were kept after a reset")
+	}
+}
+
+func TestFakeProviderErrors(t *testing.T) {
+	tests := []struct {
+		behavior FakeBehavior
+		err      error
+		taskId   bool
+	}{
+		{FakeBehavior{ZeroBalanceRate: 1}, ErrZeroBalance, false},
+		{FakeBehavior{UnsolvableRate: 1}, ErrUnsolvable, true},
+		{FakeBehavior{TimeoutRate: 1}, ErrTimeout, true},
+	}
+
+	for _, test := range tests {
+		fake := NewFakeProvider()
+		fake.Script(CaptchaTypeHCaptcha, test.behavior)
+
+		_, err := fake.SolveHCaptcha(context.Background(), NewSettings(), &HCaptchaPayload{})
+		if !errors.Is(err, test.err) {
+			t.Errorf("expected %v, got %v", test.err, err)
+			continue
+		}
+
+		var providerErr *ProviderError
+		if !errors.As(err, &providerErr) || (providerErr.TaskId != "") != test.taskId {
+			t.Errorf("%v: unexpected task id in %v", test.err, err)
+		}
+
+		if calls := fake.Calls(); len(calls) != 1 || !errors.Is(calls[0].Err, test.err) {
+			t.Errorf("%v: expected the failed call to be recorded, got %+v", test.err, calls)
+		}
+	}
+}
+
+func TestFakeProviderRates(t *testing.T) {
+	fake := NewFakeProvider()
+	fake.SetSeed(1)
+	fake.Script(CaptchaTypeImage, FakeBehavior{UnsolvableRate: 0.3})
+
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		if _, err := fake.SolveImageCaptcha(context.Background(), NewSettings(), &ImageCaptchaPayload{}); err != nil {
+			failed++
+		}
+	}
+
+	if failed < 250 || failed > 350 {
+		t.Errorf("expected about 300 failures, got %d", failed)
+	}
+}
+
+func TestFakeProviderLatency(t *testing.T) {
+	fake := NewFakeProvider()
+	fake.Script(CaptchaTypeRecaptchaV2, FakeBehavior{Latency: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := fake.SolveRecaptchaV2(ctx, NewSettings(), &RecaptchaV2Payload{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the latency to be cut short by the context, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("solving took %v", elapsed)
+	}
+}
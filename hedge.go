@@ -0,0 +1,195 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// HedgeResult describes how a hedged solve went, see HedgedProvider.SetOnResult.
+type HedgeResult struct {
+	// Winner is the name of the provider that answered first, empty if every provider failed
+	Winner string
+
+	// Launched is the amount of providers the payload was submitted to
+	Launched int
+
+	// Duplicates is the amount of tasks that were still being solved when the winner answered,
+	// these are cancelled but have usually already been paid for
+	Duplicates int
+
+	// Latency is the time it took to get an answer or to fail
+	Latency time.Duration
+
+	// Err is the error that was returned, nil if a provider answered
+	Err error
+}
+
+// HedgedProvider submits the payload to the primary provider and, if it didn't answer within the hedge delay,
+// also to the secondary providers. The first answer wins and the remaining solves are cancelled.
+// The delay and the callback can be changed while solving.
+type HedgedProvider struct {
+	primary     IProvider
+	secondaries []IProvider
+
+	mu       sync.Mutex
+	delay    time.Duration
+	onResult func(result HedgeResult)
+}
+
+func NewHedgedProvider(delay time.Duration, primary IProvider, secondaries ...IProvider) *HedgedProvider {
+	return &HedgedProvider{
+		primary:     primary,
+		secondaries: secondaries,
+		delay:       delay,
+	}
+}
+
+func (h *HedgedProvider) Name() string {
+	return "hedged"
+}
+
+// SetDelay sets the time the primary provider gets to answer before the secondary providers are used as well
+func (h *HedgedProvider) SetDelay(delay time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.delay = delay
+}
+
+// SetOnResult sets a callback that is called after every solve, it can be used to tune the hedge delay
+func (h *HedgedProvider) SetOnResult(onResult func(result HedgeResult)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.onResult = onResult
+}
+
+func (h *HedgedProvider) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	return h.solve(ctx, func(ctx context.Context, provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveImageCaptcha(ctx, settings, payload)
+	})
+}
+
+func (h *HedgedProvider) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	return h.solve(ctx, func(ctx context.Context, provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveRecaptchaV2(ctx, settings, payload)
+	})
+}
+
+func (h *HedgedProvider) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	return h.solve(ctx, func(ctx context.Context, provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveRecaptchaV3(ctx, settings, payload)
+	})
+}
+
+func (h *HedgedProvider) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	return h.solve(ctx, func(ctx context.Context, provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveHCaptcha(ctx, settings, payload)
+	})
+}
+
+func (h *HedgedProvider) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	return h.solve(ctx, func(ctx context.Context, provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveTurnstile(ctx, settings, payload)
+	})
+}
+
+func (h *HedgedProvider) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return h.solve(ctx, func(ctx context.Context, provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveCoordinates(ctx, settings, payload)
+	})
+}
+
+func (h *HedgedProvider) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return h.solve(ctx, func(ctx context.Context, provider IProvider) (ICaptchaResponse, error) {
+		return provider.SolveCustom(ctx, settings, payload)
+	})
+}
+
+func (h *HedgedProvider) solve(ctx context.Context, solve func(ctx context.Context, provider IProvider) (ICaptchaResponse, error)) (ICaptchaResponse, error) {
+	type outcome struct {
+		provider IProvider
+		resp     ICaptchaResponse
+		err      error
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered so that the losing goroutines never block after we returned
+	outcomes := make(chan outcome, 1+len(h.secondaries))
+	launch := func(provider IProvider) {
+		go func() {
+			resp, err := solve(ctx, provider)
+			outcomes <- outcome{provider: provider, resp: resp, err: err}
+		}()
+	}
+
+	launch(h.primary)
+	launched, pending := 1, 1
+
+	h.mu.Lock()
+	delay := h.delay
+	h.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	hedge := timer.C
+
+	hedgeNow := func() {
+		hedge = nil
+		for _, provider := range h.secondaries {
+			launch(provider)
+			launched++
+			pending++
+		}
+	}
+
+	var errs []error
+	for {
+		select {
+		case <-hedge:
+			hedgeNow()
+		case out := <-outcomes:
+			pending--
+			if out.err == nil {
+				winner := providerName(out.provider)
+				h.report(HedgeResult{Winner: winner, Launched: launched, Duplicates: pending, Latency: time.Since(start)})
+				return withProvider(out.resp, winner), nil
+			}
+
+			errs = append(errs, out.err)
+
+			// the primary failed before the delay, there is no point in waiting any longer
+			if hedge != nil && shouldFailover(out.err) {
+				hedgeNow()
+			}
+
+			if pending == 0 {
+				err := errors.Join(errs...)
+				h.report(HedgeResult{Launched: launched, Latency: time.Since(start), Err: err})
+				return nil, err
+			}
+		case <-ctx.Done():
+			err := errors.Join(append(errs, ctx.Err())...)
+			h.report(HedgeResult{Launched: launched, Duplicates: pending, Latency: time.Since(start), Err: err})
+			return nil, err
+		}
+	}
+}
+
+// report passes the result to the callback, which is called without holding the mutex so that it can tune the delay.
+func (h *HedgedProvider) report(result HedgeResult) {
+	h.mu.Lock()
+	onResult := h.onResult
+	h.mu.Unlock()
+
+	if onResult != nil {
+		onResult(result)
+	}
+}
+
+var _ IProvider = (*HedgedProvider)(nil)
@@ -0,0 +1,71 @@
+package anticaptcha
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHedgedProvider(t *testing.T) {
+	primary := &stubProvider{name: "primary", solution: "slow", latency: time.Second}
+	secondary := &stubProvider{name: "secondary", solution: "fast"}
+
+	var result HedgeResult
+	hedged := NewHedgedProvider(10*time.Millisecond, primary, secondary)
+	hedged.SetOnResult(func(r HedgeResult) {
+		result = r
+	})
+
+	resp, err := NewCaptchaSolver(hedged).SolveRecaptchaV2(context.Background(), &RecaptchaV2Payload{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, _ := resp.Solution(); solution != "fast" {
+		t.Errorf("expected the secondary to win, got %q", solution)
+	}
+
+	if result.Winner != "secondary" || result.Launched != 2 || result.Duplicates != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestHedgedProviderPrimaryInTime(t *testing.T) {
+	primary := &stubProvider{name: "primary", solution: "answer"}
+	secondary := &stubProvider{name: "secondary", solution: "answer"}
+
+	hedged := NewHedgedProvider(time.Second, primary, secondary)
+	if _, err := NewCaptchaSolver(hedged).SolveRecaptchaV2(context.Background(), &RecaptchaV2Payload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if secondary.calls != 0 {
+		t.Errorf("secondary should not be used when the primary answers within the delay")
+	}
+}
+
+func TestHedgedProviderTuning(t *testing.T) {
+	primary := &stubProvider{name: "primary", solution: "answer", latency: 5 * time.Millisecond}
+	secondary := &stubProvider{name: "secondary", solution: "answer"}
+
+	// the delay is tuned from the results while other solves are running
+	hedged := NewHedgedProvider(time.Millisecond, primary, secondary)
+	hedged.SetOnResult(func(r HedgeResult) {
+		hedged.SetDelay(r.Latency)
+	})
+
+	cs := NewCaptchaSolver(hedged)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cs.SolveRecaptchaV2(context.Background(), &RecaptchaV2Payload{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,171 @@
+package anticaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// secretFields are the parameters holding api keys, proxy credentials and the callback URLs carrying their token,
+// they are never logged. The proxy parameter of 2Captcha holds login:password@host:port.
+var secretFields = map[string]bool{
+	"clientKey":     true,
+	"key":           true,
+	"callbackUrl":   true,
+	"pingback":      true,
+	"proxyLogin":    true,
+	"proxyPassword": true,
+	"proxy":         true,
+}
+
+// imageFields are the parameters holding base64 images, only their size is logged
+var imageFields = map[string]bool{
+	"body":            true,
+	"imginstructions": true,
+}
+
+const redacted = "[REDACTED]"
+
+// requestLog is what is known about a request once it completed.
+type requestLog struct {
+	provider    string
+	method      string
+	url         string
+	contentType string
+	body        []byte
+	attempt     int
+	sent        bool
+	status      int
+	respBody    []byte
+	duration    time.Duration
+	err         error
+}
+
+// logRequest logs a request at debug level. The api key and images are always redacted, the bodies are only
+// included in wire dump mode.
+func (s *Settings) logRequest(ctx context.Context, r requestLog) {
+	if s.logger == nil || !s.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("provider", r.provider),
+		slog.String("method", r.method),
+		slog.String("url", redactURL(r.url)),
+		slog.Int("attempt", r.attempt),
+		slog.Duration("duration", r.duration),
+	}
+
+	if r.status != 0 {
+		attrs = append(attrs, slog.Int("status", r.status))
+	}
+
+	if r.err != nil {
+		attrs = append(attrs, slog.String("error", r.err.Error()))
+	}
+
+	if s.wireDump {
+		attrs = append(attrs,
+			slog.String("request", redactBody(r.contentType, r.body)),
+			slog.String("response", string(r.respBody)),
+		)
+	}
+
+	s.logger.LogAttrs(ctx, slog.LevelDebug, "anticaptcha request", attrs...)
+}
+
+// redactURL hides the api key in the query of rawUrl.
+func redactURL(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+
+	query := u.Query()
+	redactValues(query)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// redactError hides the api key in the URL that the errors of http.Client carry.
+func redactError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &url.Error{Op: urlErr.Op, URL: redactURL(urlErr.URL), Err: urlErr.Err}
+	}
+
+	return err
+}
+
+// redactBody hides the api key and images in a JSON or form encoded body, other bodies are replaced by their size.
+func redactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		var value any
+		if err := json.Unmarshal(body, &value); err != nil {
+			break
+		}
+
+		redacted, err := json.Marshal(redactJSON(value))
+		if err != nil {
+			break
+		}
+
+		return string(redacted)
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			break
+		}
+
+		redactValues(values)
+		return values.Encode()
+	}
+
+	return fmt.Sprintf("[%d bytes]", len(body))
+}
+
+func redactValues(values url.Values) {
+	for name, v := range values {
+		switch {
+		case secretFields[name]:
+			values[name] = []string{redacted}
+		case imageFields[name]:
+			values[name] = []string{fmt.Sprintf("[%d bytes]", len(strings.Join(v, "")))}
+		}
+	}
+}
+
+func redactJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for name, field := range v {
+			switch {
+			case secretFields[name]:
+				v[name] = redacted
+			case imageFields[name]:
+				if image, ok := field.(string); ok {
+					v[name] = fmt.Sprintf("[%d bytes]", len(image))
+				}
+			default:
+				v[name] = redactJSON(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,100 @@
+package anticaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogging(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/in.php":
+			w.Write([]byte(`{"status":1,"request":"99"}`))
+		case "/res.php":
+			w.Write([]byte(`{"status":1,"request":"answer"}`))
+		}
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	cs := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "secret-key"))
+	cs.SetInitialWaitTime(0)
+	cs.SetPollInterval(time.Millisecond)
+	cs.SetLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	cs.SetWireDump(true)
+
+	image := strings.Repeat("aW1hZ2U=", 100)
+	if _, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{Base64String: image}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := logs.String()
+	if strings.Count(out, "anticaptcha request") != 2 {
+		t.Errorf("expected a log line per request, got:\n%v", out)
+	}
+
+	if strings.Contains(out, "secret-key") || strings.Contains(out, image) {
+		t.Errorf("the api key or the image was logged:\n%v", out)
+	}
+
+	if !strings.Contains(out, "body=%5B800+bytes%5D") || !strings.Contains(out, `"request\":\"answer\"`) {
+		t.Errorf("expected the redacted wire dump, got:\n%v", out)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	body := `{"clientKey":"secret","task":{"type":"ImageToTextTask","body":"aW1hZ2U="}}`
+	got := redactBody("application/json", []byte(body))
+	if got != `{"clientKey":"[REDACTED]","task":{"body":"[8 bytes]","type":"ImageToTextTask"}}` {
+		t.Errorf("unexpected redaction %v", got)
+	}
+
+	if got := redactURL("https://2captcha.com/res.php?action=get&key=secret"); got != "https://2captcha.com/res.php?action=get&key=%5BREDACTED%5D" {
+		t.Errorf("unexpected redaction %v", got)
+	}
+
+	if got := redactBody("application/x-www-form-urlencoded", []byte("proxy=login:password@host:8080&proxytype=HTTP")); got != "proxy=%5BREDACTED%5D&proxytype=HTTP" {
+		t.Errorf("unexpected redaction %v", got)
+	}
+
+	if got := redactBody("text/plain", []byte("secret")); got != "[6 bytes]" {
+		t.Errorf("unexpected redaction %v", got)
+	}
+}
+
+func TestLoggingTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the connection is closed without a response
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	provider := NewCustomTwoCaptcha(srv.URL, "SUPERSECRET")
+	cs := NewCaptchaSolver(provider,
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+
+	handle := &TaskHandle{Provider: provider.Name(), TaskId: "7", CaptchaType: CaptchaTypeImage}
+	_, err := cs.Poll(context.Background(), handle)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+
+	if !strings.Contains(logs.String(), "error=") {
+		t.Fatalf("the error wasn't logged:\n%v", logs.String())
+	}
+
+	if strings.Contains(logs.String(), "SUPERSECRET") || strings.Contains(err.Error(), "SUPERSECRET") {
+		t.Errorf("the api key was logged:\n%v\n%v", logs.String(), err)
+	}
+}
@@ -0,0 +1,329 @@
+// Package metrics exports the tasks solved by a CaptchaSolver in the Prometheus text format.
+//
+//	m := metrics.New()
+//	cs.AddObserver(m)
+//	http.Handle("/metrics", m)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/packman80/anticaptcha"
+)
+
+// SolveTimeBuckets are the upper bounds in seconds of the time to solve histogram
+var SolveTimeBuckets = []float64{1, 2.5, 5, 10, 15, 20, 30, 45, 60, 90, 120, 180, 300}
+
+// PollBuckets are the upper bounds of the polls per task histogram
+var PollBuckets = []float64{1, 2, 3, 5, 8, 13, 21, 34}
+
+// Metrics is an anticaptcha.Observer that aggregates the tasks it is notified about and serves them in the
+// Prometheus text format. It is safe for concurrent use and can be registered on several solvers.
+type Metrics struct {
+	mu        sync.Mutex
+	submitted map[taskLabels]float64
+	solved    map[taskLabels]float64
+	failed    map[failLabels]float64
+	spent     map[taskLabels]float64
+	reported  map[reportLabels]float64
+	solveTime map[taskLabels]*histogram
+	polls     map[taskLabels]*histogram
+	inFlight  map[taskLabels]float64
+	tasks     map[taskKey]struct{}
+	balance   map[string]float64
+}
+
+type taskLabels struct {
+	provider    string
+	captchaType anticaptcha.CaptchaType
+}
+
+// taskKey identifies a task in flight, the submission time tells apart the tasks of providers without task ids
+type taskKey struct {
+	provider    string
+	taskId      string
+	submittedAt int64
+}
+
+func keyOf(e anticaptcha.TaskEvent) taskKey {
+	return taskKey{provider: e.Provider, taskId: e.TaskId, submittedAt: e.SubmittedAt.UnixNano()}
+}
+
+type failLabels struct {
+	taskLabels
+	class anticaptcha.ErrorClass
+}
+
+type reportLabels struct {
+	taskLabels
+	correct bool
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func New() *Metrics {
+	return &Metrics{
+		submitted: map[taskLabels]float64{},
+		solved:    map[taskLabels]float64{},
+		failed:    map[failLabels]float64{},
+		spent:     map[taskLabels]float64{},
+		reported:  map[reportLabels]float64{},
+		solveTime: map[taskLabels]*histogram{},
+		polls:     map[taskLabels]*histogram{},
+		inFlight:  map[taskLabels]float64{},
+		tasks:     map[taskKey]struct{}{},
+		balance:   map[string]float64{},
+	}
+}
+
+func labelsOf(e anticaptcha.TaskEvent) taskLabels {
+	return taskLabels{provider: e.Provider, captchaType: e.CaptchaType}
+}
+
+func (m *Metrics) TaskCreated(e anticaptcha.TaskEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := labelsOf(e)
+	m.submitted[l]++
+	m.inFlight[l]++
+	m.tasks[keyOf(e)] = struct{}{}
+}
+
+func (m *Metrics) TaskPolled(anticaptcha.PollEvent) {}
+
+func (m *Metrics) TaskSolved(e anticaptcha.SolveEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := labelsOf(e.TaskEvent)
+	m.done(e.TaskEvent)
+	m.solved[l]++
+	m.spent[l] += e.Cost
+	m.histogram(m.solveTime, l, SolveTimeBuckets).observe(e.Latency.Seconds())
+	m.histogram(m.polls, l, PollBuckets).observe(float64(e.Polls))
+}
+
+func (m *Metrics) TaskFailed(e anticaptcha.FailEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.done(e.TaskEvent)
+	m.failed[failLabels{taskLabels: labelsOf(e.TaskEvent), class: e.Class}]++
+}
+
+func (m *Metrics) TaskReported(e anticaptcha.ReportEvent) {
+	if e.Err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reported[reportLabels{taskLabels: labelsOf(e.TaskEvent), correct: e.Correct}]++
+}
+
+// BalanceChecked records the balance returned by CaptchaSolver.Balance
+func (m *Metrics) BalanceChecked(provider string, balance float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.balance[provider] = balance
+}
+
+// done takes the task out of the in-flight gauge, tasks that failed before being created were never in it.
+// m.mu must be held.
+func (m *Metrics) done(e anticaptcha.TaskEvent) {
+	key := keyOf(e)
+	if _, ok := m.tasks[key]; !ok {
+		return
+	}
+
+	delete(m.tasks, key)
+	m.inFlight[labelsOf(e)]--
+}
+
+// histogram returns the histogram for the labels, m.mu must be held.
+func (m *Metrics) histogram(histograms map[taskLabels]*histogram, l taskLabels, bounds []float64) *histogram {
+	h, ok := histograms[l]
+	if !ok {
+		h = &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+		histograms[l] = h
+	}
+
+	return h
+}
+
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	header(cw, "anticaptcha_tasks_submitted_total", "counter", "Tasks accepted by the provider.")
+	writeTaskValues(cw, "anticaptcha_tasks_submitted_total", m.submitted)
+
+	header(cw, "anticaptcha_tasks_solved_total", "counter", "Tasks that were solved.")
+	writeTaskValues(cw, "anticaptcha_tasks_solved_total", m.solved)
+
+	header(cw, "anticaptcha_tasks_failed_total", "counter", "Tasks that could not be created or solved, by error class.")
+	for _, l := range sortedKeys(m.failed, func(l failLabels) string { return l.key() + string(l.class) }) {
+		sample(cw, "anticaptcha_tasks_failed_total", l.pairs("error_class", string(l.class)), m.failed[l])
+	}
+
+	header(cw, "anticaptcha_tasks_reported_total", "counter", "Solutions reported to the provider.")
+	for _, l := range sortedKeys(m.reported, func(l reportLabels) string { return l.key() + strconv.FormatBool(l.correct) }) {
+		sample(cw, "anticaptcha_tasks_reported_total", l.pairs("correct", strconv.FormatBool(l.correct)), m.reported[l])
+	}
+
+	header(cw, "anticaptcha_spent_total", "counter", "Cost of the solved tasks as reported by the provider.")
+	writeTaskValues(cw, "anticaptcha_spent_total", m.spent)
+
+	header(cw, "anticaptcha_tasks_in_flight", "gauge", "Tasks that were created and are not solved or failed yet.")
+	writeTaskValues(cw, "anticaptcha_tasks_in_flight", m.inFlight)
+
+	header(cw, "anticaptcha_solve_duration_seconds", "histogram", "Time between the submission and the solution of a task.")
+	writeHistograms(cw, "anticaptcha_solve_duration_seconds", m.solveTime)
+
+	header(cw, "anticaptcha_task_polls", "histogram", "Polls needed per solved task.")
+	writeHistograms(cw, "anticaptcha_task_polls", m.polls)
+
+	header(cw, "anticaptcha_balance", "gauge", "Last seen balance of the account.")
+	providers := make([]string, 0, len(m.balance))
+	for provider := range m.balance {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		sample(cw, "anticaptcha_balance", []string{"provider", provider}, m.balance[provider])
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+func writeTaskValues(w *countingWriter, name string, values map[taskLabels]float64) {
+	for _, l := range sortedKeys(values, taskLabels.key) {
+		sample(w, name, l.pairs(), values[l])
+	}
+}
+
+func writeHistograms(w *countingWriter, name string, histograms map[taskLabels]*histogram) {
+	for _, l := range sortedKeys(histograms, taskLabels.key) {
+		h := histograms[l]
+		for i, bound := range h.bounds {
+			sample(w, name+"_bucket", l.pairs("le", formatFloat(bound)), float64(h.counts[i]))
+		}
+		sample(w, name+"_bucket", l.pairs("le", "+Inf"), float64(h.count))
+		sample(w, name+"_sum", l.pairs(), h.sum)
+		sample(w, name+"_count", l.pairs(), float64(h.count))
+	}
+}
+
+func (l taskLabels) key() string {
+	return l.provider + "\x00" + string(l.captchaType) + "\x00"
+}
+
+func (l taskLabels) pairs(extra ...string) []string {
+	return append([]string{"provider", l.provider, "captcha_type", string(l.captchaType)}, extra...)
+}
+
+func sortedKeys[K comparable, V any](m map[K]V, key func(K) string) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return key(keys[i]) < key(keys[j])
+	})
+
+	return keys
+}
+
+func header(w *countingWriter, name, kind, help string) {
+	w.printf("# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+// sample writes a line of the exposition format, pairs alternates label names and values.
+func sample(w *countingWriter, name string, pairs []string, value float64) {
+	labels := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, fmt.Sprintf("%v=\"%v\"", pairs[i], escape(pairs[i+1])))
+	}
+
+	w.printf("%v{%v} %v\n", name, strings.Join(labels, ","), formatFloat(value))
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter keeps the first error and the amount of bytes written, as required by io.WriterTo.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
+
+var (
+	_ anticaptcha.Observer        = (*Metrics)(nil)
+	_ anticaptcha.BalanceObserver = (*Metrics)(nil)
+	_ http.Handler                = (*Metrics)(nil)
+)
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/packman80/anticaptcha"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New()
+
+	submittedAt := time.Now()
+	solved := anticaptcha.TaskEvent{Provider: "2captcha", CaptchaType: anticaptcha.CaptchaTypeImage, TaskId: "1", SubmittedAt: submittedAt}
+	pending := anticaptcha.TaskEvent{Provider: "2captcha", CaptchaType: anticaptcha.CaptchaTypeImage, TaskId: "2", SubmittedAt: submittedAt}
+	failed := anticaptcha.TaskEvent{Provider: "2captcha", CaptchaType: anticaptcha.CaptchaTypeRecaptchaV2, SubmittedAt: submittedAt}
+
+	m.TaskCreated(solved)
+	m.TaskCreated(pending)
+	m.TaskSolved(anticaptcha.SolveEvent{TaskEvent: solved, Latency: 4 * time.Second, Cost: 0.001, Polls: 2})
+	m.TaskFailed(anticaptcha.FailEvent{TaskEvent: failed, Class: anticaptcha.ErrorClassZeroBalance, Err: errors.New("zero balance")})
+	m.BalanceChecked("2captcha", 12.5)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	expected := []string{
+		`# TYPE anticaptcha_tasks_submitted_total counter`,
+		`anticaptcha_tasks_submitted_total{provider="2captcha",captcha_type="image"} 2`,
+		`anticaptcha_tasks_solved_total{provider="2captcha",captcha_type="image"} 1`,
+		`anticaptcha_tasks_failed_total{provider="2captcha",captcha_type="recaptcha_v2",error_class="zero_balance"} 1`,
+		`anticaptcha_tasks_in_flight{provider="2captcha",captcha_type="image"} 1`,
+		`anticaptcha_solve_duration_seconds_bucket{provider="2captcha",captcha_type="image",le="2.5"} 0`,
+		`anticaptcha_solve_duration_seconds_bucket{provider="2captcha",captcha_type="image",le="5"} 1`,
+		`anticaptcha_solve_duration_seconds_bucket{provider="2captcha",captcha_type="image",le="+Inf"} 1`,
+		`anticaptcha_solve_duration_seconds_sum{provider="2captcha",captcha_type="image"} 4`,
+		`anticaptcha_task_polls_bucket{provider="2captcha",captcha_type="image",le="2"} 1`,
+		`anticaptcha_spent_total{provider="2captcha",captcha_type="image"} 0.001`,
+		`anticaptcha_balance{provider="2captcha"} 12.5`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%v", line, body)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if got := escape("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("unexpected escaping %q", got)
+	}
+}
+
+// balanceProvider only answers balance requests.
+type balanceProvider struct {
+	anticaptcha.IProvider
+}
+
+func (balanceProvider) Balance(ctx context.Context, settings *anticaptcha.Settings) (float64, error) {
+	return 3, nil
+}
+
+func TestMetricsBalanceObserver(t *testing.T) {
+	m := New()
+	cs := anticaptcha.NewCaptchaSolver(balanceProvider{})
+	cs.AddObserver(m)
+
+	if _, err := cs.Balance(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	if _, err := m.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sb.String(), `anticaptcha_balance{provider="metrics.balanceProvider"} 3`) {
+		t.Errorf("missing balance in:\n%v", sb.String())
+	}
+}
+
+func TestMetricsCircuitOpen(t *testing.T) {
+	m := New()
+	fake := anticaptcha.NewFakeProvider()
+	fake.Script(anticaptcha.CaptchaTypeImage, anticaptcha.FakeBehavior{ZeroBalanceRate: 1})
+	breaker := anticaptcha.NewCircuitBreakerProvider(fake, anticaptcha.CircuitBreakerConfig{ConsecutiveFailures: 1, OpenTimeout: time.Hour})
+	cs := anticaptcha.NewCaptchaSolver(breaker, anticaptcha.WithObserver(m))
+
+	for i := 0; i < 2; i++ {
+		cs.SolveImageCaptcha(context.Background(), &anticaptcha.ImageCaptchaPayload{})
+	}
+
+	if len(fake.Calls()) != 1 {
+		t.Fatalf("expected the open circuit to refuse the second task, got %d calls", len(fake.Calls()))
+	}
+
+	var sb strings.Builder
+	if _, err := m.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`anticaptcha_tasks_failed_total{provider="fake",captcha_type="image",error_class="zero_balance"} 1`,
+		`anticaptcha_tasks_failed_total{provider="fake",captcha_type="image",error_class="circuit_open"} 1`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(sb.String(), line+"\n") {
+			t.Errorf("missing %q in:\n%v", line, sb.String())
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Observer is notified about the lifecycle of every task solved with the solver it is registered on, see
+// CaptchaSolver.AddObserver. Callbacks are made synchronously from the goroutine that is solving the task, so they
+// have to be quick and safe for concurrent use. Embed NopObserver to only implement some of them.
+type Observer interface {
+	// TaskCreated is called once the provider accepted the task
+	TaskCreated(event TaskEvent)
+
+	// TaskPolled is called after every poll of the result
+	TaskPolled(event PollEvent)
+
+	// TaskSolved is called when the solution was received
+	TaskSolved(event SolveEvent)
+
+	// TaskFailed is called when the task could not be created or solved
+	TaskFailed(event FailEvent)
+
+	// TaskReported is called after the solution was reported as correct or incorrect
+	TaskReported(event ReportEvent)
+}
+
+// BalanceObserver can be implemented by an Observer to be notified of the balance returned by CaptchaSolver.Balance.
+type BalanceObserver interface {
+	BalanceChecked(provider string, balance float64)
+}
+
+// TaskEvent identifies the task an event is about.
+type TaskEvent struct {
+	Provider    string
+	CaptchaType CaptchaType
+
+	// TaskId is empty when the task was never created, or when the provider answers right away like CapGuru
+	TaskId      string
+	SubmittedAt time.Time
+}
+
+type PollEvent struct {
+	TaskEvent
+
+	// Attempt is the number of the poll, starting at 1
+	Attempt int
+
+	// Ready is true when the poll returned the solution
+	Ready bool
+
+	// Err is the error of the poll, if any
+	Err error
+}
+
+type SolveEvent struct {
+	TaskEvent
+
+	// Latency is the time between the submission of the task and the solution
+	Latency time.Duration
+
+	// Cost is the cost reported by the provider, 0 when unknown
+	Cost  float64
+	Polls int
+}
+
+type FailEvent struct {
+	TaskEvent
+
+	// Latency is the time between the submission of the task and the failure
+	Latency time.Duration
+	Class   ErrorClass
+	Err     error
+}
+
+type ReportEvent struct {
+	TaskEvent
+
+	// Correct is true for ReportGood and false for ReportBad
+	Correct bool
+
+	// Err is the error returned by the provider, if any
+	Err error
+}
+
+// NopObserver implements every Observer callback as a no-op.
+type NopObserver struct{}
+
+func (NopObserver) TaskCreated(TaskEvent)    {}
+func (NopObserver) TaskPolled(PollEvent)     {}
+func (NopObserver) TaskSolved(SolveEvent)    {}
+func (NopObserver) TaskFailed(FailEvent)     {}
+func (NopObserver) TaskReported(ReportEvent) {}
+
+// ErrorClass is a coarse classification of errors, e.g. to be used as a metric label.
+type ErrorClass string
+
+const (
+	ErrorClassZeroBalance    ErrorClass = "zero_balance"
+	ErrorClassUnsolvable     ErrorClass = "unsolvable"
+	ErrorClassInvalidKey     ErrorClass = "invalid_key"
+	ErrorClassNoSlot         ErrorClass = "no_slot"
+	ErrorClassUnsupported    ErrorClass = "unsupported"
+	ErrorClassTimeout        ErrorClass = "timeout"
+	ErrorClassBadPayload     ErrorClass = "bad_payload"
+	ErrorClassUnavailable    ErrorClass = "unavailable"
+	ErrorClassCircuitOpen    ErrorClass = "circuit_open"
+	ErrorClassBudgetExceeded ErrorClass = "budget_exceeded"
+	ErrorClassCanceled       ErrorClass = "canceled"
+	ErrorClassUnknown        ErrorClass = "unknown"
+)
+
+// ClassifyError returns the class of the sentinel error wrapped by err, ErrorClassUnknown if there is none.
+func ClassifyError(err error) ErrorClass {
+	classes := []struct {
+		err   error
+		class ErrorClass
+	}{
+		{context.Canceled, ErrorClassCanceled},
+		{context.DeadlineExceeded, ErrorClassCanceled},
+		{ErrZeroBalance, ErrorClassZeroBalance},
+		{ErrUnsolvable, ErrorClassUnsolvable},
+		{ErrInvalidKey, ErrorClassInvalidKey},
+		{ErrNoSlot, ErrorClassNoSlot},
+		{ErrUnsupported, ErrorClassUnsupported},
+		{ErrTimeout, ErrorClassTimeout},
+		{ErrBadPayload, ErrorClassBadPayload},
+		{ErrUnavailable, ErrorClassUnavailable},
+		{ErrCircuitOpen, ErrorClassCircuitOpen},
+		{ErrBudgetExceeded, ErrorClassBudgetExceeded},
+	}
+
+	for _, c := range classes {
+		if errors.Is(err, c.err) {
+			return c.class
+		}
+	}
+
+	return ErrorClassUnknown
+}
+
+func taskEvent(handle *TaskHandle) TaskEvent {
+	return TaskEvent{
+		Provider:    handle.Provider,
+		CaptchaType: handle.CaptchaType,
+		TaskId:      handle.TaskId,
+		SubmittedAt: handle.SubmittedAt,
+	}
+}
+
+func (s *Settings) observeCreated(handle *TaskHandle) {
+	for _, o := range s.observers {
+		o.TaskCreated(taskEvent(handle))
+	}
+}
+
+func (s *Settings) observePolled(handle *TaskHandle, attempt int, ready bool, err error) {
+	for _, o := range s.observers {
+		o.TaskPolled(PollEvent{TaskEvent: taskEvent(handle), Attempt: attempt, Ready: ready, Err: err})
+	}
+}
+
+func (s *Settings) observeSolved(handle *TaskHandle, result *CaptchaResponse) {
+	for _, o := range s.observers {
+		o.TaskSolved(SolveEvent{
+			TaskEvent: taskEvent(handle),
+			Latency:   result.solvedAt.Sub(result.submittedAt),
+			Cost:      result.cost,
+			Polls:     result.polls,
+		})
+	}
+}
+
+func (s *Settings) observeFailed(handle *TaskHandle, err error) {
+	for _, o := range s.observers {
+		o.TaskFailed(FailEvent{
+			TaskEvent: taskEvent(handle),
+			Latency:   time.Since(handle.SubmittedAt),
+			Class:     ClassifyError(err),
+			Err:       err,
+		})
+	}
+}
+
+// observeRefused notifies the observers about a task that was refused before it was submitted, so that it never got
+// a task id. Providers refuse payloads they can't build a task for, decorators like CircuitBreakerProvider refuse
+// tasks to protect the provider.
+func (s *Settings) observeRefused(provider string, captchaType CaptchaType, err error) {
+	s.observeFailed(&TaskHandle{Provider: provider, CaptchaType: captchaType, SubmittedAt: time.Now()}, err)
+}
+
+// refuseUnsupported returns the error for a captcha type the provider doesn't solve and notifies the observers.
+func refuseUnsupported(settings *Settings, provider string, captchaType CaptchaType) error {
+	err := unsupportedError(provider, captchaType)
+	settings.observeRefused(provider, captchaType, err)
+	return err
+}
+
+func (s *Settings) observeReported(result *CaptchaResponse, correct bool, err error) {
+	if s == nil {
+		return
+	}
+
+	for _, o := range s.observers {
+		o.TaskReported(ReportEvent{
+			TaskEvent: TaskEvent{
+				Provider:    result.provider,
+				CaptchaType: result.captchaType,
+				TaskId:      result.taskId,
+				SubmittedAt: result.submittedAt,
+			},
+			Correct: correct,
+			Err:     err,
+		})
+	}
+}
+
+func (s *Settings) observeBalance(provider string, balance float64) {
+	for _, o := range s.observers {
+		if b, ok := o.(BalanceObserver); ok {
+			b.BalanceChecked(provider, balance)
+		}
+	}
+}
+
+// submitTask creates a task with create and notifies the observers.
+func submitTask(settings *Settings, provider string, captchaType CaptchaType, create func() (string, error)) (*TaskHandle, error) {
+	handle := &TaskHandle{Provider: provider, CaptchaType: captchaType, SubmittedAt: time.Now()}
+
+	taskId, err := create()
+	if err != nil {
+		settings.observeFailed(handle, err)
+		return nil, err
+	}
+
+	handle.TaskId = taskId
+	settings.observeCreated(handle)
+	return handle, nil
+}
@@ -0,0 +1,178 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingObserver records the events it receives as short strings.
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(format string, args ...any) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) TaskCreated(e TaskEvent) {
+	o.record("created %v", e.TaskId)
+}
+
+func (o *recordingObserver) TaskPolled(e PollEvent) {
+	o.record("polled %v %v %v", e.TaskId, e.Attempt, e.Ready)
+}
+
+func (o *recordingObserver) TaskSolved(e SolveEvent) {
+	o.record("solved %v %v %v", e.TaskId, e.Cost, e.Polls)
+}
+
+func (o *recordingObserver) TaskFailed(e FailEvent) {
+	o.record("failed %v %v", e.TaskId, e.Class)
+}
+
+func (o *recordingObserver) TaskReported(e ReportEvent) {
+	o.record("reported %v %v", e.TaskId, e.Correct)
+}
+
+func TestObserver(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/createTask":
+			w.Write([]byte(`{"errorId":0,"taskId":7}`))
+		case "/getTaskResult":
+			if polls.Add(1) == 1 {
+				w.Write([]byte(`{"errorId":0,"status":"processing"}`))
+				return
+			}
+			w.Write([]byte(`{"errorId":0,"status":"ready","cost":"0.0007","solution":{"text":"answer"}}`))
+		case "/reportIncorrectImageCaptcha":
+			w.Write([]byte(`{"errorId":0}`))
+		}
+	}))
+	defer srv.Close()
+
+	observer := &recordingObserver{}
+	cs := NewCaptchaSolver(NewCustomAntiCaptcha(srv.URL, "key"))
+	cs.SetInitialWaitTime(0)
+	cs.SetPollInterval(time.Millisecond)
+	cs.AddObserver(observer)
+
+	ctx := context.Background()
+	resp, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.ReportIncorrect(ctx, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"created 7",
+		"polled 7 1 false",
+		"polled 7 2 true",
+		"solved 7 0.0007 2",
+		"reported 7 false",
+	}
+
+	if fmt.Sprint(observer.events) != fmt.Sprint(expected) {
+		t.Errorf("unexpected events %q", observer.events)
+	}
+}
+
+func TestObserverFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":0,"request":"ERROR_ZERO_BALANCE"}`))
+	}))
+	defer srv.Close()
+
+	observer := &recordingObserver{}
+	cs := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "key"))
+	cs.AddObserver(observer)
+
+	if _, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{}); !errors.Is(err, ErrZeroBalance) {
+		t.Fatalf("expected ErrZeroBalance, got %v", err)
+	}
+
+	if fmt.Sprint(observer.events) != "[failed  zero_balance]" {
+		t.Errorf("unexpected events %q", observer.events)
+	}
+}
+
+func TestObserverRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("refused tasks must not be sent, got a request to %v", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	badProxy := &Proxy{Type: "ftp", Address: "127.0.0.1", Port: 8080}
+	tests := []struct {
+		name     string
+		provider IProvider
+		solve    func(cs *CaptchaSolver) error
+		event    string
+	}{
+		{"unsupported type", NewCustomCapGuruCaptcha(srv.URL, "key"), func(cs *CaptchaSolver) error {
+			_, err := cs.SolveHCaptcha(ctx, &HCaptchaPayload{})
+			return err
+		}, "failed  unsupported"},
+		{"unsupported payload", NewCustomWhiteCaptcha(srv.URL, "key"), func(cs *CaptchaSolver) error {
+			_, err := cs.Submit(ctx, &TurnstilePayload{})
+			return err
+		}, "failed  unsupported"},
+		{"invalid proxy", NewCustomAntiCaptcha(srv.URL, "key"), func(cs *CaptchaSolver) error {
+			_, err := cs.SolveRecaptchaV2(ctx, &RecaptchaV2Payload{Proxy: badProxy})
+			return err
+		}, "failed  bad_payload"},
+		{"invalid proxy", NewCustomTwoCaptcha(srv.URL, "key"), func(cs *CaptchaSolver) error {
+			_, err := cs.SolveHCaptcha(ctx, &HCaptchaPayload{Proxy: badProxy})
+			return err
+		}, "failed  bad_payload"},
+		{"unknown payload", NewCustomTwoCaptcha(srv.URL, "key"), func(cs *CaptchaSolver) error {
+			_, err := cs.Solve(ctx, "payload")
+			return err
+		}, "failed  bad_payload"},
+	}
+
+	for _, test := range tests {
+		observer := &recordingObserver{}
+		cs := NewCaptchaSolver(test.provider, WithObserver(observer))
+		if err := test.solve(cs); err == nil {
+			t.Errorf("%v: expected the task to be refused", test.name)
+		}
+
+		if fmt.Sprint(observer.events) != "["+test.event+"]" {
+			t.Errorf("%v on %v: unexpected events %q", test.name, providerName(test.provider), observer.events)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err   error
+		class ErrorClass
+	}{
+		{&ProviderError{Err: ErrNoSlot}, ErrorClassNoSlot},
+		{fmt.Errorf("wrapped: %w", context.Canceled), ErrorClassCanceled},
+		{&ProviderError{Err: ErrBudgetExceeded}, ErrorClassBudgetExceeded},
+		{errors.New("other"), ErrorClassUnknown},
+	}
+
+	for _, tt := range tests {
+		if class := ClassifyError(tt.err); class != tt.class {
+			t.Errorf("%v: expected %v, got %v", tt.err, tt.class, class)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package anticaptcha
+
+import (
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Option changes the settings of a CaptchaSolver when passed to NewCaptchaSolver, or of a single call when passed
+// to one of its methods, e.g. cs.SolveImageCaptcha(ctx, payload, WithPollInterval(time.Second)).
+type Option func(settings *Settings)
+
+// WithClient sets the client that is used when interacting with APIs of providers
+func WithClient(client *http.Client) Option {
+	return func(s *Settings) {
+		s.client = client
+	}
+}
+
+// WithInitialWaitTime sets the time that is being waited after submitting a task to a provider before polling,
+// for every captcha type without a timing profile of its own
+func WithInitialWaitTime(waitTime time.Duration) Option {
+	return func(s *Settings) {
+		s.initialWaitTime = waitTime
+		s.hasInitialWaitTime = true
+	}
+}
+
+// WithPollInterval sets the time that is being waited in between result polls, for every captcha type without
+// a timing profile of its own
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *Settings) {
+		s.pollInterval = interval
+		s.hasPollInterval = true
+	}
+}
+
+// WithMaxRetries sets the maximum amount of polling
+func WithMaxRetries(maxRetries int) Option {
+	return func(s *Settings) {
+		s.maxRetries = maxRetries
+	}
+}
+
+// WithPollStrategy sets the strategy that decides when results are polled
+func WithPollStrategy(strategy PollStrategy) Option {
+	return func(s *Settings) {
+		s.pollStrategy = strategy
+	}
+}
+
+// WithPollUntilDeadline makes polling run until the context deadline or the max solve duration of the captcha type
+func WithPollUntilDeadline(enabled bool) Option {
+	return func(s *Settings) {
+		s.pollUntilDeadline = enabled
+	}
+}
+
+// WithMaxSolveDuration limits how long tasks of the captcha type are polled for when polling until the deadline,
+// it overrides the max duration of the timing profile of the captcha type
+func WithMaxSolveDuration(captchaType CaptchaType, duration time.Duration) Option {
+	return func(s *Settings) {
+		if s.timingProfiles == nil {
+			s.timingProfiles = map[CaptchaType]TimingProfile{}
+		}
+
+		profile := s.timingProfiles[captchaType]
+		profile.MaxDuration = duration
+		s.timingProfiles[captchaType] = profile
+	}
+}
+
+// WithRetryPolicy sets how requests that failed because of the network or an overloaded provider are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(s *Settings) {
+		s.retryPolicy = policy
+	}
+}
+
+// WithRateLimiter makes requests wait on the limiter
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(s *Settings) {
+		s.rateLimiter = limiter
+	}
+}
+
+// WithObserver registers an observer that is notified about every task
+func WithObserver(observer Observer) Option {
+	return func(s *Settings) {
+		s.observers = append(s.observers, observer)
+	}
+}
+
+// WithLogger sets the logger requests and responses are logged to at debug level
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Settings) {
+		s.logger = logger
+	}
+}
+
+// WithWireDump adds the redacted request and the response bodies to the logs
+func WithWireDump(enabled bool) Option {
+	return func(s *Settings) {
+		s.wireDump = enabled
+	}
+}
+
+// clone returns a copy of the settings that can be changed without affecting s.
+func (s *Settings) clone() *Settings {
+	c := *s
+	c.observers = slices.Clone(s.observers)
+	c.timingProfiles = maps.Clone(s.timingProfiles)
+	c.providerTimingProfiles = maps.Clone(s.providerTimingProfiles)
+	return &c
+}
+
+// with returns s when there are no options, otherwise a copy with the options applied.
+func (s *Settings) with(opts []Option) *Settings {
+	if len(opts) == 0 {
+		return s
+	}
+
+	c := s.clone()
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
@@ -0,0 +1,96 @@
+package anticaptcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// settingsProvider records the settings of the last image captcha it was asked to solve.
+type settingsProvider struct {
+	stubProvider
+	settings *Settings
+}
+
+func (p *settingsProvider) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	p.settings = settings
+	return p.solve(ctx)
+}
+
+func TestOptions(t *testing.T) {
+	provider := &settingsProvider{}
+	cs := NewCaptchaSolver(provider, WithPollInterval(time.Second), WithMaxRetries(3))
+	ctx := context.Background()
+
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	base := provider.settings
+	if base.pollInterval != time.Second || base.maxRetries != 3 || base.timing("test", CaptchaTypeImage).InitialWait != defaultTimingProfiles[CaptchaTypeImage].InitialWait {
+		t.Fatalf("options were not applied: %+v", base)
+	}
+
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}, WithPollInterval(time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	if provider.settings.pollInterval != time.Millisecond || provider.settings.maxRetries != 3 {
+		t.Errorf("per-call option was not applied: %+v", provider.settings)
+	}
+
+	if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if provider.settings != base {
+		t.Errorf("per-call option leaked into the solver settings")
+	}
+
+	cs.SetMaxRetries(5)
+	if base.maxRetries != 3 {
+		t.Errorf("setter changed a snapshot that was handed out")
+	}
+}
+
+func TestSettersRace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/in.php":
+			w.Write([]byte(`{"status":1,"request":"1"}`))
+		case "/res.php":
+			w.Write([]byte(`{"status":1,"request":"answer"}`))
+		}
+	}))
+	defer srv.Close()
+
+	cs := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "key"), WithInitialWaitTime(0))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			if _, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{}); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			cs.SetPollInterval(time.Millisecond)
+			cs.SetMaxSolveDuration(CaptchaTypeImage, time.Minute)
+			cs.AddObserver(NopObserver{})
+		}()
+	}
+
+	wg.Wait()
+	if n := len(cs.settings.Load().observers); n != 10 {
+		t.Errorf("expected 10 observers, got %d", n)
+	}
+}
@@ -10,6 +10,9 @@ type RecaptchaV2Payload struct {
 
 	// IsInvisibleCaptcha Enable if endpoint has invisible Recaptcha V2
 	IsInvisibleCaptcha bool
+
+	// Proxy makes the workers solve the captcha through the proxy, the captcha is solved without one when nil
+	Proxy *Proxy
 }
 
 type RecaptchaV3Payload struct {
@@ -28,6 +31,10 @@ type RecaptchaV3Payload struct {
 
 	// MinScore defaults to 0.3, accepted values are 0.3, 0.6, 0.9
 	MinScore float32
+
+	// Proxy makes the workers solve the captcha through the proxy, the captcha is solved without one when nil.
+	// AntiCaptcha compatible providers don't support it and return an error wrapping ErrUnsupported.
+	Proxy *Proxy
 }
 
 type TurnstilePayload struct {
@@ -37,6 +44,9 @@ type TurnstilePayload struct {
 	// EndpointKey is the Recaptcha Key
 	// Can be found on the Endpoint URL page
 	EndpointKey string
+
+	// Proxy makes the workers solve the captcha through the proxy, the captcha is solved without one when nil
+	Proxy *Proxy
 }
 
 type ImageCaptchaPayload struct {
@@ -69,4 +79,7 @@ type HCaptchaPayload struct {
 	// EndpointKey is the HCaptcha Key
 	// Can be found on the Endpoint URL page
 	EndpointKey string
+
+	// Proxy makes the workers solve the captcha through the proxy, the captcha is solved without one when nil
+	Proxy *Proxy
 }
@@ -0,0 +1,197 @@
+package anticaptcha
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// PollInfo describes the poll that is about to be scheduled.
+type PollInfo struct {
+	// Provider is the name of the provider the task was submitted to
+	Provider string
+
+	// CaptchaType is the type of captcha that is being solved
+	CaptchaType CaptchaType
+
+	// Attempt is the amount of polls that were already done, 0 when scheduling the first poll
+	Attempt int
+
+	// Elapsed is the time since the task was submitted
+	Elapsed time.Duration
+
+	// InitialWait and Interval are the timing profile of the provider and captcha type
+	InitialWait time.Duration
+	Interval    time.Duration
+}
+
+// PollStrategy decides when the result of a task is polled.
+type PollStrategy interface {
+	// Next returns the delay before the next poll. For the first poll (Attempt 0) the delay is counted from
+	// the submission of the task, for the others from the previous poll.
+	Next(info PollInfo) time.Duration
+
+	// Observe is called with the time it took the provider to solve a task of the captcha type. When the provider
+	// doesn't report when the task was solved, it is estimated as the middle between the poll that found the
+	// solution and the previous one, or as the time of the poll when the first one found it.
+	Observe(provider string, captchaType CaptchaType, solveTime time.Duration)
+}
+
+type fixedPollStrategy struct{}
+
+// NewFixedPollStrategy waits the initial wait time of the timing profile before the first poll and the poll
+// interval in between the others, this is the default strategy.
+func NewFixedPollStrategy() PollStrategy {
+	return fixedPollStrategy{}
+}
+
+func (fixedPollStrategy) Next(info PollInfo) time.Duration {
+	if info.Attempt == 0 {
+		return info.InitialWait
+	}
+
+	return info.Interval
+}
+
+func (fixedPollStrategy) Observe(string, CaptchaType, time.Duration) {}
+
+type backoffPollStrategy struct {
+	multiplier  float64
+	maxInterval time.Duration
+	jitter      float64
+}
+
+// NewBackoffPollStrategy multiplies the poll interval by multiplier after every poll, up to maxInterval.
+// Every delay is randomized by up to ±jitter (a fraction, e.g. 0.2) so that tasks submitted together
+// are not polled in lockstep.
+func NewBackoffPollStrategy(multiplier float64, maxInterval time.Duration, jitter float64) PollStrategy {
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	return &backoffPollStrategy{
+		multiplier:  multiplier,
+		maxInterval: maxInterval,
+		jitter:      math.Max(0, math.Min(jitter, 1)),
+	}
+}
+
+func (s *backoffPollStrategy) Next(info PollInfo) time.Duration {
+	if info.Attempt == 0 {
+		return withJitter(info.InitialWait, s.jitter)
+	}
+
+	delay := time.Duration(float64(info.Interval) * math.Pow(s.multiplier, float64(info.Attempt-1)))
+	if s.maxInterval > 0 && (delay > s.maxInterval || delay < 0) {
+		delay = s.maxInterval
+	}
+
+	return withJitter(delay, s.jitter)
+}
+
+func (s *backoffPollStrategy) Observe(string, CaptchaType, time.Duration) {}
+
+func withJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter == 0 || d <= 0 {
+		return d
+	}
+
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// adaptiveSamples is the amount of solve times remembered per provider and captcha type
+const adaptiveSamples = 50
+
+type adaptivePollStrategy struct {
+	minSamples int
+	fallback   PollStrategy
+
+	mu      sync.Mutex
+	history map[adaptiveKey]*solveHistory
+}
+
+type adaptiveKey struct {
+	provider    string
+	captchaType CaptchaType
+}
+
+// solveHistory is a ring buffer of the most recent solve times.
+type solveHistory struct {
+	samples []time.Duration
+	next    int
+}
+
+// NewAdaptivePollStrategy learns how long each provider takes to solve each captcha type and polls for the
+// first time around the median solve time. Polls after that are spaced by a quarter of the spread between
+// the median and the 90th percentile, capped by the poll interval of the settings.
+// Until minSamples solves were observed the fallback strategy is used, NewFixedPollStrategy if nil.
+func NewAdaptivePollStrategy(minSamples int, fallback PollStrategy) PollStrategy {
+	if minSamples < 1 {
+		minSamples = 5
+	}
+
+	if fallback == nil {
+		fallback = NewFixedPollStrategy()
+	}
+
+	return &adaptivePollStrategy{
+		minSamples: minSamples,
+		fallback:   fallback,
+		history:    map[adaptiveKey]*solveHistory{},
+	}
+}
+
+func (s *adaptivePollStrategy) Next(info PollInfo) time.Duration {
+	s.mu.Lock()
+	history := s.history[adaptiveKey{info.Provider, info.CaptchaType}]
+	var sorted []time.Duration
+	if history != nil && len(history.samples) >= s.minSamples {
+		sorted = slices.Clone(history.samples)
+	}
+	s.mu.Unlock()
+
+	if sorted == nil {
+		return s.fallback.Next(info)
+	}
+
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	if info.Attempt == 0 {
+		return median
+	}
+
+	interval := (sorted[len(sorted)*9/10] - median) / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	if info.Interval > 0 && interval > info.Interval {
+		interval = info.Interval
+	}
+
+	return interval
+}
+
+func (s *adaptivePollStrategy) Observe(provider string, captchaType CaptchaType, solveTime time.Duration) {
+	s.fallback.Observe(provider, captchaType, solveTime)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := adaptiveKey{provider, captchaType}
+	history, ok := s.history[key]
+	if !ok {
+		history = &solveHistory{}
+		s.history[key] = history
+	}
+
+	if len(history.samples) < adaptiveSamples {
+		history.samples = append(history.samples, solveTime)
+		return
+	}
+
+	history.samples[history.next] = solveTime
+	history.next = (history.next + 1) % adaptiveSamples
+}
@@ -0,0 +1,118 @@
+package anticaptcha
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoffPollStrategy(t *testing.T) {
+	strategy := NewBackoffPollStrategy(2, 8*time.Second, 0)
+	info := PollInfo{InitialWait: 3 * time.Second, Interval: time.Second}
+
+	expected := []time.Duration{3 * time.Second, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second}
+	for attempt, want := range expected {
+		info.Attempt = attempt
+		if got := strategy.Next(info); got != want {
+			t.Errorf("attempt %d: expected %v, got %v", attempt, want, got)
+		}
+	}
+
+	jittered := NewBackoffPollStrategy(1, 0, 0.5)
+	for i := 0; i < 100; i++ {
+		got := jittered.Next(PollInfo{Attempt: 1, Interval: time.Second})
+		if got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("jittered delay %v out of range", got)
+		}
+	}
+}
+
+func TestAdaptivePollStrategy(t *testing.T) {
+	strategy := NewAdaptivePollStrategy(3, nil)
+	info := PollInfo{Provider: "test", CaptchaType: CaptchaTypeImage, InitialWait: 10 * time.Second, Interval: 5 * time.Second}
+
+	if got := strategy.Next(info); got != 10*time.Second {
+		t.Fatalf("expected the fallback before enough samples, got %v", got)
+	}
+
+	for _, d := range []time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second, 30 * time.Second} {
+		strategy.Observe("test", CaptchaTypeImage, d)
+	}
+
+	if got := strategy.Next(info); got != 4*time.Second {
+		t.Errorf("expected the first poll at the median, got %v", got)
+	}
+
+	info.Attempt = 1
+	if got := strategy.Next(info); got != 5*time.Second {
+		t.Errorf("expected the interval to be capped, got %v", got)
+	}
+
+	// other captcha types are learned separately
+	info.CaptchaType = CaptchaTypeRecaptchaV2
+	info.Attempt = 0
+	if got := strategy.Next(info); got != 10*time.Second {
+		t.Errorf("expected the fallback for another type, got %v", got)
+	}
+}
+
+func TestAdaptivePollStrategyOnePoll(t *testing.T) {
+	// every task takes 60ms to solve and 2Captcha doesn't report when it was solved
+	const solveTime = 60 * time.Millisecond
+	var (
+		mu      sync.Mutex
+		created = map[string]time.Time{}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/in.php":
+			taskId := strconv.Itoa(len(created) + 1)
+			created[taskId] = time.Now()
+			fmt.Fprintf(w, `{"status":1,"request":"%v"}`, taskId)
+		case "/res.php":
+			if time.Since(created[r.URL.Query().Get("id")]) < solveTime {
+				w.Write([]byte(`{"status":0,"request":"CAPCHA_NOT_READY"}`))
+				return
+			}
+			w.Write([]byte(`{"status":1,"request":"answer"}`))
+		}
+	}))
+	defer srv.Close()
+
+	cs := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "key"),
+		WithInitialWaitTime(20*time.Millisecond),
+		WithPollInterval(20*time.Millisecond),
+		WithPollStrategy(NewAdaptivePollStrategy(3, nil)),
+	)
+
+	polls := make([]int, 12)
+	for i := range polls {
+		resp, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		polls[i] = resp.(IDetailedCaptchaResponse).Polls()
+	}
+
+	if polls[0] < 3 {
+		t.Errorf("expected the fixed strategy to poll several times at first, got %v", polls)
+	}
+
+	// once it learned the solve time, the first poll finds the solution
+	for _, n := range polls[len(polls)-3:] {
+		if n != 1 {
+			t.Errorf("expected a single poll per task, got %v", polls)
+			break
+		}
+	}
+}
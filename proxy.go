@@ -0,0 +1,58 @@
+package anticaptcha
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+type ProxyType string
+
+const (
+	ProxyHTTP   ProxyType = "http"
+	ProxySOCKS4 ProxyType = "socks4"
+	ProxySOCKS5 ProxyType = "socks5"
+)
+
+// Proxy is the proxy the workers of the provider should solve the captcha through, this is needed for
+// websites that bind tokens to the IP address that solved the captcha.
+type Proxy struct {
+	// Type is the protocol of the proxy, defaults to http
+	Type ProxyType
+
+	// Address is the IP address or hostname of the proxy
+	Address string
+
+	// Port is the port of the proxy
+	Port int
+
+	// Login and Password are only needed if the proxy requires authentication
+	Login    string
+	Password string
+}
+
+func (p *Proxy) proxyType() ProxyType {
+	if p.Type == "" {
+		return ProxyHTTP
+	}
+
+	return p.Type
+}
+
+func (p *Proxy) validate() error {
+	switch p.proxyType() {
+	case ProxyHTTP, ProxySOCKS4, ProxySOCKS5:
+	default:
+		return fmt.Errorf("unknown proxy type %q", p.Type)
+	}
+
+	if p.Address == "" || p.Port <= 0 || p.Port > 65535 {
+		return fmt.Errorf("invalid proxy address %q", net.JoinHostPort(p.Address, strconv.Itoa(p.Port)))
+	}
+
+	return nil
+}
+
+func proxyError(provider string, captchaType CaptchaType, err error) *ProviderError {
+	return &ProviderError{Provider: provider, CaptchaType: captchaType, Description: err.Error(), Err: ErrBadPayload}
+}
@@ -0,0 +1,43 @@
+package anticaptcha
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestProxyEncoding(t *testing.T) {
+	proxy := &Proxy{Type: ProxySOCKS5, Address: "10.0.0.1", Port: 1080, Login: "user", Password: "pass"}
+
+	task := map[string]any{"type": "HCaptchaTaskProxyless"}
+	if err := setAntiCaptchaProxy(task, "HCaptchaTask", proxy); err != nil {
+		t.Fatal(err)
+	}
+
+	if task["type"] != "HCaptchaTask" || task["proxyType"] != "socks5" || task["proxyAddress"] != "10.0.0.1" ||
+		task["proxyPort"] != 1080 || task["proxyLogin"] != "user" || task["proxyPassword"] != "pass" {
+		t.Errorf("unexpected anticaptcha task %v", task)
+	}
+
+	values := &url.Values{}
+	if err := setTwoCaptchaProxy(values, proxy); err != nil {
+		t.Fatal(err)
+	}
+
+	if values.Get("proxy") != "user:pass@10.0.0.1:1080" || values.Get("proxytype") != "SOCKS5" {
+		t.Errorf("unexpected 2captcha params %v", values.Encode())
+	}
+
+	if err := setTwoCaptchaProxy(&url.Values{}, &Proxy{Address: "10.0.0.1"}); err == nil {
+		t.Errorf("expected an error for a proxy without port")
+	}
+
+	// there is no proxy variant of the reCAPTCHA v3 task
+	if _, task, err := NewAntiCaptcha("key").buildTask(&RecaptchaV3Payload{Proxy: proxy}); task != nil || !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected reCAPTCHA v3 with a proxy to be unsupported, got %v: %v", task, err)
+	}
+
+	if _, task, err := NewAntiCaptcha("key").buildTask(&RecaptchaV3Payload{}); err != nil || task["type"] != "RecaptchaV3TaskProxyless" {
+		t.Errorf("unexpected task %v: %v", task, err)
+	}
+}
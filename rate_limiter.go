@@ -0,0 +1,135 @@
+package anticaptcha
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/packman80/anticaptcha/internal"
+)
+
+// RateLimit is the budget of a token bucket.
+type RateLimit struct {
+	// Rate is the amount of requests per second, 0 or less means unlimited
+	Rate float64
+
+	// Burst is the amount of requests that can be sent at once after being idle, defaults to 1
+	Burst int
+}
+
+// RateLimiter limits how often tasks are created and how often results are polled, with a separate token bucket
+// per provider for each. Requests wait for a token instead of failing, so the limiter can be shared by many
+// solvers that use the same api key.
+type RateLimiter struct {
+	create RateLimit
+	poll   RateLimit
+
+	mu      sync.Mutex
+	buckets map[rateKey]*tokenBucket
+}
+
+// requestKind tells the rate limiter which budget a request counts against
+type requestKind int
+
+const (
+	// requestCreate submits a task
+	requestCreate requestKind = iota
+
+	// requestPoll is any other request, e.g. fetching a result, reporting or checking the balance
+	requestPoll
+)
+
+type rateKey struct {
+	provider string
+	kind     requestKind
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(create, poll RateLimit) *RateLimiter {
+	return &RateLimiter{
+		create:  create,
+		poll:    poll,
+		buckets: map[rateKey]*tokenBucket{},
+	}
+}
+
+// WaitCreate waits until a task can be created with the provider
+func (l *RateLimiter) WaitCreate(ctx context.Context, provider string) error {
+	return l.wait(ctx, provider, requestCreate)
+}
+
+// WaitPoll waits until the provider can be polled
+func (l *RateLimiter) WaitPoll(ctx context.Context, provider string) error {
+	return l.wait(ctx, provider, requestPoll)
+}
+
+func (l *RateLimiter) wait(ctx context.Context, provider string, kind requestKind) error {
+	if l == nil {
+		return nil
+	}
+
+	limit := l.poll
+	if kind == requestCreate {
+		limit = l.create
+	}
+
+	if limit.Rate <= 0 {
+		return nil
+	}
+
+	key := rateKey{provider, kind}
+
+	l.mu.Lock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = newTokenBucket(limit)
+		l.buckets[key] = bucket
+	}
+	delay := bucket.reserve(time.Now())
+	l.mu.Unlock()
+
+	if err := internal.SleepWithContext(ctx, delay); err != nil {
+		// hand the token back so that cancelled requests don't slow down the others
+		l.mu.Lock()
+		bucket.tokens++
+		l.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	return &tokenBucket{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long to wait for it. The amount of tokens goes negative when requests
+// are queued, so that every waiting request gets its own slot.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.limit.Rate * float64(time.Second))
+}
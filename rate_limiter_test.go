@@ -0,0 +1,55 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{Rate: 100, Burst: 2}, RateLimit{})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.WaitCreate(ctx, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// two requests go through right away, the other two wait 10ms each
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected the burst to be limited, took %v", elapsed)
+	}
+
+	// other providers and polling have budgets of their own
+	start = time.Now()
+	if err := limiter.WaitCreate(ctx, "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := limiter.WaitPoll(ctx, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Millisecond {
+		t.Errorf("expected no wait, took %v", elapsed)
+	}
+}
+
+func TestRateLimiterContext(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{Rate: 0.1}, RateLimit{})
+	if err := limiter.WaitCreate(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := limiter.WaitCreate(ctx, "test"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package anticaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// IReporter is implemented by providers that accept feedback about the solutions they returned.
+// Reporting incorrect solutions usually gets them refunded and improves the accuracy of the workers.
+type IReporter interface {
+	// ReportCorrect reports that the solution of the task was accepted
+	ReportCorrect(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) error
+
+	// ReportIncorrect reports that the solution of the task was rejected
+	ReportIncorrect(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) error
+}
+
+// IReportableResponse is implemented by responses that know which provider they came from.
+type IReportableResponse interface {
+	ICaptchaResponse
+
+	// ReportGood reports the solution as correct to the provider that solved it
+	ReportGood(ctx context.Context) error
+
+	// ReportBad reports the solution as incorrect to the provider that solved it
+	ReportBad(ctx context.Context) error
+}
+
+func reportUnsupportedError(provider string, captchaType CaptchaType, taskId string) *ProviderError {
+	return &ProviderError{
+		Provider:    provider,
+		TaskId:      taskId,
+		CaptchaType: captchaType,
+		Description: "reporting is not supported",
+		Err:         ErrUnsupported,
+	}
+}
+
+// resReport reports a task to APIs that follow the res.php?action=reportgood/reportbad convention of 2Captcha.
+func resReport(ctx context.Context, settings *Settings, provider, baseUrl, apiKey, action string, captchaType CaptchaType, taskId string) error {
+	type response struct {
+		Status    int    `json:"status"`
+		Request   string `json:"request"`
+		ErrorText string `json:"error_text"`
+	}
+
+	body := &url.Values{}
+	body.Set("key", apiKey)
+	body.Set("action", action)
+	body.Set("id", taskId)
+	body.Set("json", "1")
+
+	fullURL := fmt.Sprintf("%v/res.php?%v", baseUrl, body.Encode())
+	respBody, err := doRequest(ctx, settings, provider, requestPoll, http.MethodGet, fullURL, "", nil)
+	if err != nil {
+		return err
+	}
+
+	var jsonResp response
+	if err := json.Unmarshal(respBody, &jsonResp); err != nil {
+		return err
+	}
+
+	if jsonResp.Status == 0 {
+		return newProviderError(twoCaptchaErrors, provider, captchaType, taskId, jsonResp.Request, jsonResp.ErrorText)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReportBad(t *testing.T) {
+	var reported []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/createTask":
+			w.Write([]byte(`{"errorId":0,"taskId":7}`))
+		case "/getTaskResult":
+			w.Write([]byte(`{"errorId":0,"status":"ready","solution":{"text":"abc"}}`))
+		case "/res.php":
+			reported = append(reported, r.URL.Query().Get("action")+" "+r.URL.Query().Get("id"))
+			w.Write([]byte(`{"status":1,"request":"OK_REPORT_RECORDED"}`))
+		default:
+			reported = append(reported, r.URL.Path)
+			w.Write([]byte(`{"errorId":0}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	cs := NewCaptchaSolver(NewCustomAntiCaptcha(srv.URL, "key"))
+	cs.SetInitialWaitTime(0)
+
+	resp, err := cs.SolveImageCaptcha(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := resp.(IReportableResponse).ReportBad(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.ReportCorrect(ctx, resp); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("reporting an image captcha as correct is not supported by AntiCaptcha, got %v", err)
+	}
+
+	if err := NewCustomTwoCaptcha(srv.URL, "key").ReportIncorrect(ctx, NewSettings(), CaptchaTypeHCaptcha, "8"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/reportIncorrectImageCaptcha", "reportbad 8"}
+	if len(reported) != len(want) || reported[0] != want[0] || reported[1] != want[1] {
+		t.Errorf("expected reports %v, got %v", want, reported)
+	}
+}
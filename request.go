@@ -0,0 +1,205 @@
+package anticaptcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/packman80/anticaptcha/internal"
+)
+
+// ErrUnavailable is returned when the provider could not be reached or answered with a server error,
+// after the retries of the retry policy were used up
+var ErrUnavailable = errors.New("provider unavailable")
+
+// maxResponseSize is the largest response body that is read from a provider
+const maxResponseSize = 1 << 20
+
+// RetryPolicy decides how requests that failed because of the network, a 5xx status or a 429 status are retried.
+// A Retry-After header sent by the provider is honoured when it asks for a longer delay than the policy.
+//
+// Requests that submit a task are only retried when they can't have created it: when the connection failed before
+// the request was sent, or when the provider answered with 429. A submission that was sent and then failed, e.g.
+// with a 5xx status or while reading the response, may have created a paid task, so it is not retried.
+type RetryPolicy struct {
+	// MaxAttempts is the amount of times a request is sent, 1 or less disables retries
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry, it doubles with every retry
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay in between retries
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy sends requests up to 3 times, waiting 1 and then 2 seconds in between.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// delay returns how long to wait before the retry that follows attempt (starting at 1)
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if p.MaxDelay > 0 && (delay > p.MaxDelay || delay < 0) {
+		delay = p.MaxDelay
+	}
+
+	return withJitter(delay, 0.2)
+}
+
+// doRequest sends a request to the provider and returns the body of the response, retrying according to the retry
+// policy of the settings and waiting on its rate limiter before every attempt. Statuses other than 2xx and HTML
+// pages are returned as a *ProviderError.
+func doRequest(ctx context.Context, settings *Settings, provider string, kind requestKind, method, url, contentType string, body []byte) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		if err := settings.rateLimiter.wait(ctx, provider, kind); err != nil {
+			return nil, err
+		}
+
+		respBody, retryAfter, err := sendRequest(ctx, settings, provider, kind, attempt, method, url, contentType, body)
+		if err == nil || retryAfter < 0 || attempt >= settings.retryPolicy.MaxAttempts || ctx.Err() != nil {
+			return respBody, err
+		}
+
+		if err := internal.SleepWithContext(ctx, max(settings.retryPolicy.delay(attempt), retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sendRequest sends the request once. retryAfter is negative when the error is not worth retrying, otherwise it is
+// the delay the provider asked for, 0 if it didn't.
+func sendRequest(ctx context.Context, settings *Settings, provider string, kind requestKind, attempt int, method, url, contentType string, body []byte) ([]byte, time.Duration, error) {
+	entry := requestLog{provider: provider, method: method, url: url, contentType: contentType, body: body, attempt: attempt}
+	start := time.Now()
+	respBody, retryAfter, err := send(ctx, settings, &entry)
+
+	// the provider may have created the task, sending it again could pay for it twice
+	if kind == requestCreate && entry.sent && entry.status != http.StatusTooManyRequests {
+		retryAfter = -1
+	}
+
+	entry.duration = time.Since(start)
+	entry.err = err
+	settings.logRequest(ctx, entry)
+
+	return respBody, retryAfter, err
+}
+
+// send does the work of sendRequest, recording the status and response on entry.
+func send(ctx context.Context, settings *Settings, entry *requestLog) ([]byte, time.Duration, error) {
+	provider := entry.provider
+
+	var reader io.Reader
+	if entry.body != nil {
+		reader = bytes.NewReader(entry.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, entry.method, entry.url, reader)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	if entry.contentType != "" {
+		req.Header.Set("Content-Type", entry.contentType)
+	}
+
+	var sent atomic.Bool
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				sent.Store(true)
+			}
+		},
+	}))
+	defer func() {
+		entry.sent = sent.Load() || entry.status != 0
+	}()
+
+	resp, err := settings.client.Do(req)
+	if err != nil {
+		// the error carries the URL, which holds the api key with some providers
+		err = redactError(err)
+		return nil, 0, &ProviderError{Provider: provider, Description: err.Error(), Err: errors.Join(ErrUnavailable, err)}
+	}
+	defer resp.Body.Close()
+	entry.status = resp.StatusCode
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	entry.respBody = respBody
+	if err != nil {
+		return nil, 0, &ProviderError{Provider: provider, Description: err.Error(), Err: errors.Join(ErrUnavailable, err)}
+	}
+
+	if len(respBody) > maxResponseSize {
+		return nil, -1, &ProviderError{Provider: provider, Description: fmt.Sprintf("response larger than %d bytes", maxResponseSize)}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return nil, retryAfter(resp.Header.Get("Retry-After")), &ProviderError{
+			Provider:    provider,
+			Code:        strconv.Itoa(resp.StatusCode),
+			Description: fmt.Sprintf("unexpected status code: %d", resp.StatusCode),
+			Err:         ErrUnavailable,
+		}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, -1, &ProviderError{
+			Provider:    provider,
+			Code:        strconv.Itoa(resp.StatusCode),
+			Description: fmt.Sprintf("unexpected status code: %d", resp.StatusCode),
+		}
+	}
+
+	// proxies and gateways in front of the provider answer with an HTML page instead of JSON
+	if trimmed := bytes.TrimSpace(respBody); len(trimmed) > 0 && trimmed[0] == '<' {
+		return nil, -1, &ProviderError{Provider: provider, Description: "unexpected HTML response", Err: ErrUnavailable}
+	}
+
+	return respBody, 0, nil
+}
+
+// retryAfter parses a Retry-After header, which holds either a number of seconds or an HTTP date.
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
+
+// taskError fills in the captcha type and task id on the errors returned by doRequest.
+func taskError(err error, captchaType CaptchaType, taskId string) error {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		if providerErr.CaptchaType == "" {
+			providerErr.CaptchaType = captchaType
+		}
+
+		if providerErr.TaskId == "" {
+			providerErr.TaskId = taskId
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,191 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoRequestRetries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{"status":1}`))
+		}
+	}))
+	defer srv.Close()
+
+	settings := NewSettings()
+	settings.retryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	body, err := doRequest(context.Background(), settings, "test", requestPoll, http.MethodGet, srv.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != `{"status":1}` || calls != 3 {
+		t.Errorf("unexpected body %q after %d calls", body, calls)
+	}
+}
+
+func TestDoRequestCreateRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/dropped":
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	settings := NewSettings()
+	settings.retryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		path  string
+		calls int32
+	}{
+		// the provider received the task, it may have been created
+		{"/unavailable", 1},
+		{"/dropped", 1},
+		// the provider refused the task
+		{"/throttled", 3},
+	}
+
+	for _, tt := range tests {
+		calls.Store(0)
+		_, err := doRequest(context.Background(), settings, "test", requestCreate, http.MethodPost, srv.URL+tt.path, "application/json", []byte(`{}`))
+		if !errors.Is(err, ErrUnavailable) {
+			t.Errorf("%v: expected ErrUnavailable, got %v", tt.path, err)
+		}
+
+		if calls.Load() != tt.calls {
+			t.Errorf("%v: expected %d attempts, got %d", tt.path, tt.calls, calls.Load())
+		}
+	}
+
+	// requests that never reached the provider are retried
+	srv.Close()
+	attempts := 0
+	settings.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, errors.New("connection refused")
+	})}
+
+	if _, err := doRequest(context.Background(), settings, "test", requestCreate, http.MethodPost, srv.URL, "application/json", []byte(`{}`)); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("expected the unsent request to be retried, got %d attempts", attempts)
+	}
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestDoRequestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/html":
+			w.Write([]byte("<html><body>Cloudflare</body></html>"))
+		case "/large":
+			w.Write([]byte(strings.Repeat("a", maxResponseSize+1)))
+		}
+	}))
+	defer srv.Close()
+
+	settings := NewSettings()
+	settings.retryPolicy = RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		path        string
+		unavailable bool
+	}{
+		{"/unavailable", true},
+		{"/forbidden", false},
+		{"/html", true},
+		{"/large", false},
+	}
+
+	for _, tt := range tests {
+		_, err := doRequest(context.Background(), settings, "test", requestPoll, http.MethodGet, srv.URL+tt.path, "", nil)
+
+		var providerErr *ProviderError
+		if !errors.As(err, &providerErr) || providerErr.Provider != "test" {
+			t.Errorf("%v: expected a provider error, got %v", tt.path, err)
+		}
+
+		if errors.Is(err, ErrUnavailable) != tt.unavailable {
+			t.Errorf("%v: unexpected error %v", tt.path, err)
+		}
+	}
+}
+
+func TestGetResultTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	settings := NewSettings()
+	settings.retryPolicy = RetryPolicy{}
+
+	providers := []struct {
+		name      string
+		getResult resultFunc
+	}{
+		{"anticaptcha", NewCustomAntiCaptcha(srv.URL, "key").getResult},
+		{"2captcha", NewCustomTwoCaptcha(srv.URL, "key").getResult},
+		{"whitecaptcha", NewCustomWhiteCaptcha(srv.URL, "key").getResult},
+	}
+
+	for _, p := range providers {
+		result, err := p.getResult(context.Background(), settings, CaptchaTypeImage, "42")
+		if result != nil || !errors.Is(err, ErrUnavailable) {
+			t.Errorf("%v: expected the transport error, got %v", p.name, err)
+		}
+
+		var providerErr *ProviderError
+		if errors.As(err, &providerErr) && providerErr.TaskId != "42" {
+			t.Errorf("%v: expected the task id on the error, got %v", p.name, err)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d := retryAfter("3"); d != 3*time.Second {
+		t.Errorf("expected 3s, got %v", d)
+	}
+
+	if d := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); d < 58*time.Second || d > time.Minute {
+		t.Errorf("expected about a minute, got %v", d)
+	}
+
+	if d := retryAfter("soon"); d != 0 {
+		t.Errorf("expected 0, got %v", d)
+	}
+}
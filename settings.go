@@ -1,6 +1,8 @@
 package anticaptcha
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -10,13 +12,72 @@ type Settings struct {
 	initialWaitTime time.Duration
 	pollInterval    time.Duration
 	maxRetries      int
+	pollStrategy    PollStrategy
+	retryPolicy     RetryPolicy
+	rateLimiter     *RateLimiter
+	observers       []Observer
+	logger          *slog.Logger
+	wireDump        bool
+
+	// pollUntilDeadline replaces maxRetries by the context deadline and the max duration of the timing profile
+	pollUntilDeadline bool
+
+	// the global timing only applies when it was set, otherwise the timing profiles are used
+	hasInitialWaitTime     bool
+	hasPollInterval        bool
+	timingProfiles         map[CaptchaType]TimingProfile
+	providerTimingProfiles map[providerTimingKey]TimingProfile
+
+	// callback replaces polling until the callback of a task arrived or callbackGrace passed
+	callback      CallbackReceiver
+	callbackGrace time.Duration
 }
 
 func NewSettings() *Settings {
 	return &Settings{
-		client:          http.DefaultClient,
-		initialWaitTime: 10 * time.Second,
-		pollInterval:    5 * time.Second,
-		maxRetries:      15,
+		client:       http.DefaultClient,
+		maxRetries:   15,
+		pollStrategy: NewFixedPollStrategy(),
+		retryPolicy:  DefaultRetryPolicy(),
+	}
+}
+
+// nextPoll asks the poll strategy for the delay before the next poll.
+func (s *Settings) nextPoll(provider string, handle *TaskHandle, attempt int) time.Duration {
+	strategy := s.pollStrategy
+	if strategy == nil {
+		strategy = NewFixedPollStrategy()
+	}
+
+	timing := s.timing(provider, handle.CaptchaType)
+	return strategy.Next(PollInfo{
+		Provider:    provider,
+		CaptchaType: handle.CaptchaType,
+		Attempt:     attempt,
+		Elapsed:     time.Since(handle.SubmittedAt),
+		InitialWait: timing.InitialWait,
+		Interval:    timing.PollInterval,
+	})
+}
+
+// solveDeadline returns the time polling has to stop at when polling until the deadline, or the zero time if
+// there is none. Polling stops a second before the context deadline so that the last poll can still complete.
+func (s *Settings) solveDeadline(ctx context.Context, provider string, handle *TaskHandle) time.Time {
+	var deadline time.Time
+	if d, ok := ctx.Deadline(); ok {
+		deadline = d.Add(-time.Second)
 	}
+
+	if limit := s.timing(provider, handle.CaptchaType).MaxDuration; limit > 0 {
+		start := handle.SubmittedAt
+		if !handle.budgetFrom.IsZero() {
+			start = handle.budgetFrom
+		}
+
+		if d := start.Add(limit); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+
+	return deadline
 }
@@ -0,0 +1,227 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/packman80/anticaptcha/internal"
+)
+
+// resultFunc fetches the result of a task, it returns a nil response while the task is not ready yet.
+type resultFunc func(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) (*CaptchaResponse, error)
+
+// pollResult waits for the task to be solved, the poll strategy of the settings decides when to poll.
+// Handles that are picked up again after a while have already spent part of the initial wait, so only the
+// remainder is waited. Polling stops after maxRetries polls, or at the solve deadline when polling until the
+// deadline, in which case the errors carry the task id so that the task can be picked up again. Tasks submitted
+// with a callback URL are only polled when the callback didn't arrive in time.
+func pollResult(ctx context.Context, settings *Settings, provider IProvider, handle *TaskHandle, getResult resultFunc) (*CaptchaResponse, error) {
+	result, err := poll(ctx, settings, provider, handle, getResult)
+	if err != nil {
+		err = taskFailed(handle, err)
+		settings.observeFailed(handle, err)
+		return nil, err
+	}
+
+	settings.observeSolved(handle, result)
+	return result, nil
+}
+
+func poll(ctx context.Context, settings *Settings, provider IProvider, handle *TaskHandle, getResult resultFunc) (*CaptchaResponse, error) {
+	name := providerName(provider)
+	deadline := settings.solveDeadline(ctx, name, handle)
+	polls := 0
+	if handle.callback && settings.callback != nil {
+		result, polled, err := pollCallback(ctx, settings, provider, handle, deadline, getResult)
+		if result != nil || err != nil {
+			return result, err
+		}
+
+		// the callback didn't arrive or wasn't genuine, the result is polled from here on
+		if polled {
+			polls = 1
+		}
+	}
+
+	// the first poll counts from the submission, after a poll triggered by the callback the interval is waited
+	wait := settings.nextPoll(name, handle, polls)
+	if polls == 0 {
+		wait -= time.Since(handle.SubmittedAt)
+	}
+	last := false
+
+	// the task is known to be unsolved at the previous poll, zero before the first one
+	var previousPoll time.Time
+	if polls > 0 {
+		previousPoll = time.Now()
+	}
+
+	for i := polls; settings.pollUntilDeadline || i < settings.maxRetries; i++ {
+		if settings.pollUntilDeadline && !deadline.IsZero() && time.Now().Add(wait).After(deadline) {
+			if last {
+				return nil, deadlineError(name, handle, ErrTimeout)
+			}
+
+			// poll one last time right at the deadline
+			wait = time.Until(deadline)
+			last = true
+		}
+
+		if err := internal.SleepWithContext(ctx, wait); err != nil {
+			if settings.pollUntilDeadline {
+				return nil, deadlineError(name, handle, err)
+			}
+
+			return nil, err
+		}
+
+		polledAt := time.Now()
+		result, err := getResult(ctx, settings, handle.CaptchaType, handle.TaskId)
+		settings.observePolled(handle, i+1, result != nil, err)
+		if err != nil {
+			return nil, err
+		}
+
+		if result != nil {
+			reported := !result.solvedAt.IsZero()
+			completeResult(result, settings, provider, handle, i+1)
+			switch {
+			case reported:
+				observeSolveTime(settings, name, handle, result.solvedAt.Sub(result.submittedAt))
+			case !previousPoll.IsZero():
+				// solved somewhere in between the two polls
+				observeSolveTime(settings, name, handle, previousPoll.Add(polledAt.Sub(previousPoll)/2).Sub(handle.SubmittedAt))
+			case handle.budgetFrom.IsZero():
+				// the first poll found the result, the task was solved by then at the latest. Handles that are
+				// picked up again may have been solved long before, so they don't tell anything.
+				observeSolveTime(settings, name, handle, polledAt.Sub(handle.SubmittedAt))
+			}
+
+			return result, nil
+		}
+
+		previousPoll = polledAt
+		wait = settings.nextPoll(name, handle, i+1)
+	}
+
+	return nil, timeoutError(name, handle.CaptchaType, handle.TaskId)
+}
+
+// pollCallback waits for the callback of the task and fetches the result once it arrived, it reports whether the
+// result was fetched. The response is nil when the result has to be polled, because the callback didn't arrive
+// within the grace period or wasn't genuine. The callback only triggers the fetch, so it doesn't have to be trusted.
+func pollCallback(ctx context.Context, settings *Settings, provider IProvider, handle *TaskHandle, deadline time.Time, getResult resultFunc) (*CaptchaResponse, bool, error) {
+	name := providerName(provider)
+	arrived, err := settings.waitCallback(ctx, handle, deadline)
+	if err != nil {
+		if settings.pollUntilDeadline {
+			return nil, false, deadlineError(name, handle, err)
+		}
+
+		return nil, false, err
+	}
+
+	if !arrived {
+		return nil, false, nil
+	}
+
+	result, err := getResult(ctx, settings, handle.CaptchaType, handle.TaskId)
+	settings.observePolled(handle, 1, result != nil, err)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if result != nil {
+		// the result is fetched as soon as the provider called back, so the solve time is as good as reported
+		completeResult(result, settings, provider, handle, 1)
+		observeSolveTime(settings, name, handle, result.solvedAt.Sub(result.submittedAt))
+	}
+
+	return result, true, nil
+}
+
+// observeSolveTime teaches the poll strategy how long the task took to solve. Unless the provider reported when the
+// task was solved, results found by a poll only tell that it was solved in between the previous poll and that one,
+// so poll passes the middle of the two, or the time of the poll when it was the first one.
+func observeSolveTime(settings *Settings, provider string, handle *TaskHandle, solveTime time.Duration) {
+	if settings.pollStrategy != nil {
+		settings.pollStrategy.Observe(provider, handle.CaptchaType, solveTime)
+	}
+}
+
+func deadlineError(provider string, handle *TaskHandle, err error) *ProviderError {
+	e := &ProviderError{
+		Provider:    provider,
+		TaskId:      handle.TaskId,
+		CaptchaType: handle.CaptchaType,
+		Err:         err,
+	}
+
+	if errors.Is(err, ErrTimeout) {
+		e.Description = "solve deadline exceeded"
+	}
+
+	return e
+}
+
+// fetchResult fetches the result once, it is what the providers use to implement IAsyncProvider.GetResult.
+func fetchResult(ctx context.Context, settings *Settings, provider IProvider, handle *TaskHandle, getResult resultFunc) (ICaptchaResponse, error) {
+	result, err := getResult(ctx, settings, handle.CaptchaType, handle.TaskId)
+	settings.observePolled(handle, 1, result != nil, err)
+	if err != nil {
+		err = taskFailed(handle, err)
+		settings.observeFailed(handle, err)
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, notReadyError(handle)
+	}
+
+	completeResult(result, settings, provider, handle, 1)
+	settings.observeSolved(handle, result)
+	return result, nil
+}
+
+// completeResult fills in the metadata that is shared by all providers. When provider implements IReporter
+// the response is bound to it, so that it can be reported later on.
+func completeResult(result *CaptchaResponse, settings *Settings, provider IProvider, handle *TaskHandle, polls int) {
+	result.taskId = handle.TaskId
+	result.provider = providerName(provider)
+	result.captchaType = handle.CaptchaType
+	result.polls = polls
+	if result.submittedAt.IsZero() {
+		result.submittedAt = handle.SubmittedAt
+	}
+	if result.solvedAt.IsZero() {
+		result.solvedAt = time.Now()
+	}
+	if reporter, ok := provider.(IReporter); ok {
+		result.reporter = reporter
+		result.settings = settings
+	}
+}
+
+// parseFloat reads numbers that some providers send as JSON strings and others as JSON numbers.
+func parseFloat(v any) float64 {
+	switch val := v.(type) {
+	case float64:
+		return val
+	case string:
+		f, _ := strconv.ParseFloat(val, 64)
+		return f
+	}
+
+	return 0
+}
+
+// unixTime converts a unix timestamp in seconds, returning the zero time when it is absent.
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,96 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPollResult(t *testing.T) {
+	settings := NewSettings().with([]Option{WithInitialWaitTime(0), WithPollInterval(time.Millisecond)})
+
+	calls := 0
+	getResult := func(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) (*CaptchaResponse, error) {
+		calls++
+		if calls < 3 {
+			return nil, nil
+		}
+
+		return &CaptchaResponse{solution: "answer", cost: 0.002, userAgent: "agent"}, nil
+	}
+
+	submittedAt := time.Now()
+	handle := &TaskHandle{Provider: "test", TaskId: "42", CaptchaType: CaptchaTypeTurnstile, SubmittedAt: submittedAt}
+	resp, err := pollResult(context.Background(), settings, &stubProvider{name: "test"}, handle, getResult)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solution, taskId := resp.Solution()
+	if solution != "answer" || taskId != "42" {
+		t.Errorf("unexpected solution %q for task %q", solution, taskId)
+	}
+
+	if resp.Provider() != "test" || resp.CaptchaType() != CaptchaTypeTurnstile || resp.Polls() != 3 {
+		t.Errorf("unexpected metadata: provider %q, type %q, polls %d", resp.Provider(), resp.CaptchaType(), resp.Polls())
+	}
+
+	if !resp.SubmittedAt().Equal(submittedAt) || resp.SolvedAt().Before(submittedAt) {
+		t.Errorf("unexpected timestamps %v - %v", resp.SubmittedAt(), resp.SolvedAt())
+	}
+}
+
+func TestPollResultTimeout(t *testing.T) {
+	settings := NewSettings().with([]Option{WithInitialWaitTime(0), WithPollInterval(time.Millisecond), WithMaxRetries(2)})
+
+	getResult := func(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) (*CaptchaResponse, error) {
+		return nil, nil
+	}
+
+	handle := &TaskHandle{Provider: "test", TaskId: "42", CaptchaType: CaptchaTypeImage, SubmittedAt: time.Now()}
+	_, err := pollResult(context.Background(), settings, &stubProvider{name: "test"}, handle, getResult)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || providerErr.TaskId != "42" {
+		t.Errorf("expected the task id to be kept, got %v", err)
+	}
+}
+
+func TestPollResultUntilDeadline(t *testing.T) {
+	settings := NewSettings().with([]Option{
+		WithInitialWaitTime(0),
+		WithPollInterval(5 * time.Millisecond),
+		WithMaxRetries(1),
+		WithPollUntilDeadline(true),
+		WithMaxSolveDuration(CaptchaTypeImage, 50*time.Millisecond),
+	})
+
+	calls := 0
+	getResult := func(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) (*CaptchaResponse, error) {
+		calls++
+		return nil, nil
+	}
+
+	handle := &TaskHandle{Provider: "test", TaskId: "42", CaptchaType: CaptchaTypeImage, SubmittedAt: time.Now()}
+	_, err := pollResult(context.Background(), settings, &stubProvider{name: "test"}, handle, getResult)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	if calls <= settings.maxRetries {
+		t.Errorf("expected polling to ignore max retries, polled %d times", calls)
+	}
+
+	if elapsed := time.Since(handle.SubmittedAt); elapsed < 50*time.Millisecond {
+		t.Errorf("gave up after %v, before the max solve duration", elapsed)
+	}
+
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Handle().TaskId != "42" {
+		t.Errorf("expected the task id to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotReady is returned by CaptchaSolver.Poll while the task is still being solved.
+var ErrNotReady = errors.New("captcha not ready")
+
+// TaskHandle identifies a task that was submitted with CaptchaSolver.Submit. It can be marshalled to JSON,
+// so that the result can be collected by another process or after a restart.
+type TaskHandle struct {
+	Provider    string      `json:"provider"`
+	TaskId      string      `json:"taskId"`
+	CaptchaType CaptchaType `json:"captchaType"`
+	SubmittedAt time.Time   `json:"submittedAt"`
+
+	// callback is set when the provider was asked to call back once the task is solved, see WithCallback
+	callback bool
+
+	// budgetFrom is the time the max solve duration counts from, SubmittedAt when zero. Tasks that are waited on
+	// again get a fresh budget.
+	budgetFrom time.Time
+}
+
+// IAsyncProvider is implemented by providers that can create a task and fetch its result in separate steps.
+type IAsyncProvider interface {
+	// CreateTask submits one of the payload types, e.g. *ImageCaptchaPayload, without waiting for the solution
+	CreateTask(ctx context.Context, settings *Settings, payload any) (*TaskHandle, error)
+
+	// GetResult fetches the result of the task once, it returns an error wrapping ErrNotReady if it isn't solved yet
+	GetResult(ctx context.Context, settings *Settings, handle *TaskHandle) (ICaptchaResponse, error)
+}
+
+func notReadyError(handle *TaskHandle) *ProviderError {
+	return &ProviderError{
+		Provider:    handle.Provider,
+		TaskId:      handle.TaskId,
+		CaptchaType: handle.CaptchaType,
+		Err:         ErrNotReady,
+	}
+}
+
+// Handle returns a handle to the task the error belongs to, e.g. to keep waiting for a task whose solve deadline
+// was exceeded with CaptchaSolver.Wait. It returns nil if no task was created.
+func (e *ProviderError) Handle() *TaskHandle {
+	if e.TaskId == "" {
+		return nil
+	}
+
+	return &TaskHandle{
+		Provider:    e.Provider,
+		TaskId:      e.TaskId,
+		CaptchaType: e.CaptchaType,
+		SubmittedAt: e.SubmittedAt,
+	}
+}
+
+// taskFailed records when the task was submitted on the errors of the task, so that ProviderError.Handle returns
+// a handle that can be waited on.
+func taskFailed(handle *TaskHandle, err error) error {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) && providerErr.TaskId == handle.TaskId && providerErr.SubmittedAt.IsZero() {
+		providerErr.SubmittedAt = handle.SubmittedAt
+	}
+
+	return err
+}
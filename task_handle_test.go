@@ -0,0 +1,113 @@
+package anticaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/packman80/anticaptcha/anticaptchatest"
+)
+
+func TestSubmitAndPoll(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/in.php":
+			w.Write([]byte(`{"status":1,"request":"99"}`))
+		case "/res.php":
+			if polls.Add(1) == 1 {
+				w.Write([]byte(`{"status":0,"request":"CAPCHA_NOT_READY"}`))
+				return
+			}
+			w.Write([]byte(`{"status":1,"request":"answer"}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	submitter := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "key"))
+	handle, err := submitter.Submit(ctx, &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the handle is picked up by another solver, as if it was another process
+	raw, err := json.Marshal(handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var restored TaskHandle
+	if err := json.Unmarshal(raw, &restored); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Provider != handle.Provider || restored.TaskId != handle.TaskId ||
+		restored.CaptchaType != handle.CaptchaType || !restored.SubmittedAt.Equal(handle.SubmittedAt) {
+		t.Errorf("handle changed after a JSON round trip: %+v != %+v", restored, *handle)
+	}
+
+	collector := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "key"))
+	if _, err := collector.Poll(ctx, &restored); !errors.Is(err, ErrNotReady) {
+		t.Fatalf("expected ErrNotReady, got %v", err)
+	}
+
+	collector.SetInitialWaitTime(0)
+	resp, err := collector.Wait(ctx, &restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, taskId := resp.Solution(); solution != "answer" || taskId != "99" {
+		t.Errorf("unexpected solution %q for task %q", solution, taskId)
+	}
+
+	other := NewCaptchaSolver(NewTwoCaptcha("key"))
+	if _, err := other.Poll(ctx, &restored); !errors.Is(err, ErrBadPayload) {
+		t.Errorf("expected handles of other providers to be rejected, got %v", err)
+	}
+}
+
+func TestWaitAfterDeadline(t *testing.T) {
+	srv := anticaptchatest.NewTwoCaptchaServer()
+	defer srv.Close()
+	srv.Enqueue(anticaptchatest.Task{NotReady: 8, Solution: "answer"})
+
+	cs := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "key"),
+		WithInitialWaitTime(0),
+		WithPollInterval(10*time.Millisecond),
+		WithPollUntilDeadline(true),
+		WithMaxSolveDuration(CaptchaTypeImage, 50*time.Millisecond),
+	)
+
+	// the recovery path of the README
+	_, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	var providerErr *ProviderError
+	if !errors.Is(err, ErrTimeout) || !errors.As(err, &providerErr) {
+		t.Fatalf("expected the solve deadline to be exceeded, got %v", err)
+	}
+
+	handle := providerErr.Handle()
+	if handle.SubmittedAt.IsZero() || time.Since(handle.SubmittedAt) > time.Second {
+		t.Fatalf("expected the handle to keep the submission time, got %v", handle.SubmittedAt)
+	}
+
+	resp, err := cs.Wait(context.Background(), handle)
+	if err != nil {
+		t.Fatalf("expected waiting again to get a fresh deadline, got %v", err)
+	}
+
+	if solution, _ := resp.Solution(); solution != "answer" || srv.Polls("1") != 9 {
+		t.Errorf("unexpected solution %q after %d polls", solution, srv.Polls("1"))
+	}
+
+	detailed := resp.(IDetailedCaptchaResponse)
+	if latency := detailed.SolvedAt().Sub(detailed.SubmittedAt()); latency <= 0 || latency > time.Second {
+		t.Errorf("unexpected solve time %v", latency)
+	}
+}
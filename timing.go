@@ -0,0 +1,103 @@
+package anticaptcha
+
+import (
+	"maps"
+	"time"
+)
+
+// TimingProfile is the polling timing used for tasks of a captcha type. Zero fields fall back to the next level,
+// from the most to the least specific: WithProviderTimingProfile, WithTimingProfile, the global WithInitialWaitTime
+// and WithPollInterval options, and finally the built-in profile of the captcha type.
+type TimingProfile struct {
+	// InitialWait is the time that is waited after submitting a task before polling
+	InitialWait time.Duration
+
+	// PollInterval is the time that is waited in between polls
+	PollInterval time.Duration
+
+	// MaxDuration is how long the task is polled for when polling until the deadline, see WithPollUntilDeadline
+	MaxDuration time.Duration
+}
+
+// defaultTimingProfiles follow the timings recommended by the documentation of 2Captcha and AntiCaptcha
+var defaultTimingProfiles = map[CaptchaType]TimingProfile{
+	CaptchaTypeImage:       {InitialWait: 5 * time.Second, PollInterval: 3 * time.Second, MaxDuration: 2 * time.Minute},
+	CaptchaTypeRecaptchaV2: {InitialWait: 15 * time.Second, PollInterval: 5 * time.Second, MaxDuration: 5 * time.Minute},
+	CaptchaTypeRecaptchaV3: {InitialWait: 15 * time.Second, PollInterval: 5 * time.Second, MaxDuration: 3 * time.Minute},
+	CaptchaTypeHCaptcha:    {InitialWait: 15 * time.Second, PollInterval: 5 * time.Second, MaxDuration: 5 * time.Minute},
+	CaptchaTypeTurnstile:   {InitialWait: 10 * time.Second, PollInterval: 5 * time.Second, MaxDuration: 3 * time.Minute},
+	CaptchaTypeCoordinates: {InitialWait: 10 * time.Second, PollInterval: 5 * time.Second, MaxDuration: 3 * time.Minute},
+	CaptchaTypeCustom:      {InitialWait: 10 * time.Second, PollInterval: 5 * time.Second, MaxDuration: 5 * time.Minute},
+}
+
+// fallbackTimingProfile is used for captcha types without a built-in profile
+var fallbackTimingProfile = TimingProfile{InitialWait: 10 * time.Second, PollInterval: 5 * time.Second}
+
+// DefaultTimingProfiles returns a copy of the built-in timing profiles
+func DefaultTimingProfiles() map[CaptchaType]TimingProfile {
+	return maps.Clone(defaultTimingProfiles)
+}
+
+type providerTimingKey struct {
+	provider    string
+	captchaType CaptchaType
+}
+
+// WithTimingProfile overrides the timing of the captcha type for every provider
+func WithTimingProfile(captchaType CaptchaType, profile TimingProfile) Option {
+	return func(s *Settings) {
+		if s.timingProfiles == nil {
+			s.timingProfiles = map[CaptchaType]TimingProfile{}
+		}
+
+		s.timingProfiles[captchaType] = profile
+	}
+}
+
+// WithProviderTimingProfile overrides the timing of the captcha type for a single provider, identified by its name
+// as used in errors, e.g. "2captcha"
+func WithProviderTimingProfile(provider string, captchaType CaptchaType, profile TimingProfile) Option {
+	return func(s *Settings) {
+		if s.providerTimingProfiles == nil {
+			s.providerTimingProfiles = map[providerTimingKey]TimingProfile{}
+		}
+
+		s.providerTimingProfiles[providerTimingKey{provider, captchaType}] = profile
+	}
+}
+
+// timing resolves the timing for tasks of the captcha type solved by provider.
+func (s *Settings) timing(provider string, captchaType CaptchaType) TimingProfile {
+	timing, ok := defaultTimingProfiles[captchaType]
+	if !ok {
+		timing = fallbackTimingProfile
+	}
+
+	if s.hasInitialWaitTime {
+		timing.InitialWait = s.initialWaitTime
+	}
+
+	if s.hasPollInterval {
+		timing.PollInterval = s.pollInterval
+	}
+
+	timing.merge(s.timingProfiles[captchaType])
+	timing.merge(s.providerTimingProfiles[providerTimingKey{provider, captchaType}])
+
+	return timing
+}
+
+// merge overrides the fields of p that are set in other.
+func (p *TimingProfile) merge(other TimingProfile) {
+	if other.InitialWait > 0 {
+		p.InitialWait = other.InitialWait
+	}
+
+	if other.PollInterval > 0 {
+		p.PollInterval = other.PollInterval
+	}
+
+	if other.MaxDuration > 0 {
+		p.MaxDuration = other.MaxDuration
+	}
+}
@@ -0,0 +1,63 @@
+package anticaptcha
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimingProfiles(t *testing.T) {
+	settings := NewSettings()
+	for _, captchaType := range []CaptchaType{
+		CaptchaTypeImage, CaptchaTypeRecaptchaV2, CaptchaTypeRecaptchaV3, CaptchaTypeHCaptcha,
+		CaptchaTypeTurnstile, CaptchaTypeCoordinates, CaptchaTypeCustom,
+	} {
+		timing := settings.timing("2captcha", captchaType)
+		if timing != DefaultTimingProfiles()[captchaType] || timing.InitialWait == 0 || timing.PollInterval == 0 {
+			t.Errorf("unexpected default timing for %v: %+v", captchaType, timing)
+		}
+	}
+
+	if image, recaptcha := settings.timing("", CaptchaTypeImage), settings.timing("", CaptchaTypeRecaptchaV2); image.InitialWait >= recaptcha.InitialWait {
+		t.Errorf("expected images to be polled before reCAPTCHA, got %v and %v", image.InitialWait, recaptcha.InitialWait)
+	}
+
+	settings = settings.with([]Option{
+		WithPollInterval(time.Second),
+		WithTimingProfile(CaptchaTypeRecaptchaV2, TimingProfile{InitialWait: 20 * time.Second}),
+		WithProviderTimingProfile("anticaptcha", CaptchaTypeRecaptchaV2, TimingProfile{PollInterval: 2 * time.Second}),
+		WithMaxSolveDuration(CaptchaTypeRecaptchaV2, time.Minute),
+	})
+
+	tests := []struct {
+		provider    string
+		captchaType CaptchaType
+		want        TimingProfile
+	}{
+		// the global poll interval replaces the built-in one
+		{"2captcha", CaptchaTypeImage, TimingProfile{5 * time.Second, time.Second, 2 * time.Minute}},
+		// the profile of the type is merged field by field
+		{"2captcha", CaptchaTypeRecaptchaV2, TimingProfile{20 * time.Second, time.Second, time.Minute}},
+		// the profile of the provider comes first
+		{"anticaptcha", CaptchaTypeRecaptchaV2, TimingProfile{20 * time.Second, 2 * time.Second, time.Minute}},
+	}
+
+	for _, test := range tests {
+		if got := settings.timing(test.provider, test.captchaType); got != test.want {
+			t.Errorf("%v %v: expected %+v, got %+v", test.provider, test.captchaType, test.want, got)
+		}
+	}
+}
+
+func TestTimingProfilesCopied(t *testing.T) {
+	profiles := DefaultTimingProfiles()
+	profiles[CaptchaTypeImage] = TimingProfile{}
+	if DefaultTimingProfiles()[CaptchaTypeImage] == (TimingProfile{}) {
+		t.Error("changing the returned profiles changed the defaults")
+	}
+
+	base := NewSettings().with([]Option{WithTimingProfile(CaptchaTypeImage, TimingProfile{InitialWait: time.Second})})
+	base.with([]Option{WithTimingProfile(CaptchaTypeImage, TimingProfile{InitialWait: time.Minute})})
+	if got := base.timing("", CaptchaTypeImage).InitialWait; got != time.Second {
+		t.Errorf("per-call profile leaked into the base settings: %v", got)
+	}
+}
@@ -0,0 +1,305 @@
+package anticaptcha
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TokenPoolOptions configures a TokenPool, zero values are replaced by defaults.
+type TokenPoolOptions struct {
+	// Size is the amount of tokens kept solved per payload, defaults to 3
+	Size int
+
+	// TTL is how long a token can be used after it was solved, defaults to 110 seconds
+	// which leaves some margin on the 120 seconds reCAPTCHA tokens are valid for
+	TTL time.Duration
+
+	// IdleTimeout stops refilling the tokens of a payload when none were requested for this long, defaults to 5 minutes
+	IdleTimeout time.Duration
+}
+
+// TokenPoolStats are the counters of a TokenPool.
+type TokenPoolStats struct {
+	// Hits is the amount of requests that were served a pre-solved token
+	Hits uint64
+
+	// Misses is the amount of requests that had to wait for a token to be solved
+	Misses uint64
+
+	// Expired is the amount of tokens that were thrown away because they were not used in time
+	Expired uint64
+}
+
+// TokenPool keeps tokens for reCAPTCHA, hCaptcha and Turnstile payloads solved in the background, so that they
+// can be handed out without waiting. Tokens are pooled per site URL, site key, captcha type and the other
+// fields of the payload. Pooling starts on the first request for a payload, other captcha types are passed
+// straight to the wrapped provider.
+type TokenPool struct {
+	provider IProvider
+	options  TokenPoolOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	queues map[string]*tokenQueue
+
+	hits, misses, expired atomic.Uint64
+}
+
+type tokenQueue struct {
+	key        string
+	tokens     []pooledToken
+	refilling  int
+	lastDemand time.Time
+
+	// solve solves a token for the payload, with the settings of the latest request. values is the context of
+	// that request without its cancellation, so that values like the budget label reach the refills.
+	solve  func(ctx context.Context) (ICaptchaResponse, error)
+	values context.Context
+}
+
+type pooledToken struct {
+	resp      ICaptchaResponse
+	expiresAt time.Time
+}
+
+func NewTokenPool(provider IProvider, options TokenPoolOptions) *TokenPool {
+	if options.Size <= 0 {
+		options.Size = 3
+	}
+
+	if options.TTL <= 0 {
+		options.TTL = 110 * time.Second
+	}
+
+	if options.IdleTimeout <= 0 {
+		options.IdleTimeout = 5 * time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TokenPool{
+		provider: provider,
+		options:  options,
+		ctx:      ctx,
+		cancel:   cancel,
+		queues:   map[string]*tokenQueue{},
+	}
+}
+
+func (p *TokenPool) Name() string {
+	return providerName(p.provider)
+}
+
+// Stats returns the hit, miss and expiry counters
+func (p *TokenPool) Stats() TokenPoolStats {
+	return TokenPoolStats{
+		Hits:    p.hits.Load(),
+		Misses:  p.misses.Load(),
+		Expired: p.expired.Load(),
+	}
+}
+
+// Close stops refilling and cancels the solves that are in progress, the pool passes requests straight to the
+// provider afterwards.
+func (p *TokenPool) Close() {
+	p.cancel()
+}
+
+func (p *TokenPool) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	return p.provider.SolveImageCaptcha(ctx, settings, payload)
+}
+
+func (p *TokenPool) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	pooled := *payload
+	key := poolKey(CaptchaTypeRecaptchaV2, pooled.EndpointUrl, pooled.EndpointKey, pooled.IsInvisibleCaptcha, proxyKey(pooled.Proxy))
+
+	return p.get(ctx, key, func(ctx context.Context) (ICaptchaResponse, error) {
+		return p.provider.SolveRecaptchaV2(ctx, settings, &pooled)
+	})
+}
+
+func (p *TokenPool) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	pooled := *payload
+	key := poolKey(CaptchaTypeRecaptchaV3, pooled.EndpointUrl, pooled.EndpointKey, pooled.Action, pooled.IsEnterprise, pooled.MinScore, proxyKey(pooled.Proxy))
+
+	return p.get(ctx, key, func(ctx context.Context) (ICaptchaResponse, error) {
+		return p.provider.SolveRecaptchaV3(ctx, settings, &pooled)
+	})
+}
+
+func (p *TokenPool) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	pooled := *payload
+	key := poolKey(CaptchaTypeHCaptcha, pooled.EndpointUrl, pooled.EndpointKey, proxyKey(pooled.Proxy))
+
+	return p.get(ctx, key, func(ctx context.Context) (ICaptchaResponse, error) {
+		return p.provider.SolveHCaptcha(ctx, settings, &pooled)
+	})
+}
+
+func (p *TokenPool) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	pooled := *payload
+	key := poolKey(CaptchaTypeTurnstile, pooled.EndpointUrl, pooled.EndpointKey, proxyKey(pooled.Proxy))
+
+	return p.get(ctx, key, func(ctx context.Context) (ICaptchaResponse, error) {
+		return p.provider.SolveTurnstile(ctx, settings, &pooled)
+	})
+}
+
+func (p *TokenPool) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return p.provider.SolveCoordinates(ctx, settings, payload)
+}
+
+func (p *TokenPool) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return p.provider.SolveCustom(ctx, settings, payload)
+}
+
+// get hands out a pooled token for key, or solves one right away when the pool is empty.
+// Either way the pool is topped up in the background.
+func (p *TokenPool) get(ctx context.Context, key string, solve func(ctx context.Context) (ICaptchaResponse, error)) (ICaptchaResponse, error) {
+	if p.ctx.Err() != nil {
+		return solve(ctx)
+	}
+
+	p.mu.Lock()
+	q, ok := p.queues[key]
+	if !ok {
+		q = &tokenQueue{key: key}
+		p.queues[key] = q
+	}
+
+	now := time.Now()
+	q.solve = solve
+	q.values = context.WithoutCancel(ctx)
+	q.lastDemand = now
+
+	p.prune(q, now)
+
+	var resp ICaptchaResponse
+	if len(q.tokens) > 0 {
+		resp = q.tokens[0].resp
+		q.tokens = q.tokens[1:]
+	}
+
+	// on a miss the token solved for this request counts towards the pool, so that a cold miss doesn't solve
+	// Size+1 tokens at once. A pool of one token is still refilled, otherwise it would never fill up.
+	reserved := 0
+	if resp == nil && p.options.Size > 1 {
+		reserved = 1
+	}
+
+	p.refill(q, reserved)
+	p.mu.Unlock()
+
+	if resp != nil {
+		p.hits.Add(1)
+		return resp, nil
+	}
+
+	p.misses.Add(1)
+	return solve(ctx)
+}
+
+// refill starts background solves until the queue holds Size tokens, besides the reserved ones that are being
+// solved for requests. Queues that are no longer in demand are dropped once they are empty. p.mu must be held.
+func (p *TokenPool) refill(q *tokenQueue, reserved int) {
+	if p.idle(q) || p.ctx.Err() != nil {
+		p.drop(q)
+		return
+	}
+
+	for len(q.tokens)+q.refilling+reserved < p.options.Size {
+		q.refilling++
+		go p.fill(q, q.values, q.solve)
+	}
+}
+
+// fill solves a token with the values of the request that triggered it, it is canceled when the pool is closed.
+func (p *TokenPool) fill(q *tokenQueue, values context.Context, solve func(ctx context.Context) (ICaptchaResponse, error)) {
+	ctx, cancel := context.WithCancel(values)
+	defer cancel()
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
+
+	resp, err := solve(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	q.refilling--
+	if err != nil {
+		// failures are not retried here to avoid hammering a provider that is down,
+		// the next request for the payload triggers a new refill
+		time.AfterFunc(time.Until(q.lastDemand.Add(p.options.IdleTimeout)), func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			p.drop(q)
+		})
+		return
+	}
+
+	solvedAt := time.Now()
+	if detailed, ok := resp.(IDetailedCaptchaResponse); ok && !detailed.SolvedAt().IsZero() {
+		solvedAt = detailed.SolvedAt()
+	}
+
+	expiresAt := solvedAt.Add(p.options.TTL)
+	q.tokens = append(q.tokens, pooledToken{resp: resp, expiresAt: expiresAt})
+
+	// replace the token once it expires, as long as the payload is still in demand
+	time.AfterFunc(time.Until(expiresAt), func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.prune(q, time.Now())
+		p.refill(q, 0)
+	})
+}
+
+// idle reports whether the payload of the queue wasn't requested within the idle timeout, p.mu must be held.
+func (p *TokenPool) idle(q *tokenQueue) bool {
+	return time.Since(q.lastDemand) >= p.options.IdleTimeout
+}
+
+// drop forgets the queue when it is empty and no longer in demand, p.mu must be held.
+func (p *TokenPool) drop(q *tokenQueue) {
+	if len(q.tokens) == 0 && q.refilling == 0 && p.idle(q) && p.queues[q.key] == q {
+		delete(p.queues, q.key)
+	}
+}
+
+// prune drops the expired tokens of the queue. Providers report when a token was solved, so tokens don't
+// necessarily expire in the order they were added. p.mu must be held.
+func (p *TokenPool) prune(q *tokenQueue, now time.Time) {
+	valid := q.tokens[:0]
+	for _, token := range q.tokens {
+		if now.After(token.expiresAt) {
+			p.expired.Add(1)
+			continue
+		}
+
+		valid = append(valid, token)
+	}
+
+	clear(q.tokens[len(valid):])
+	q.tokens = valid
+}
+
+// poolKey builds an unambiguous key out of the fields that identify a token.
+func poolKey(parts ...any) string {
+	return fmt.Sprintf("%#v", parts)
+}
+
+func proxyKey(proxy *Proxy) string {
+	if proxy == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%v://%v@%v:%v", proxy.proxyType(), proxy.Login, proxy.Address, proxy.Port)
+}
+
+var _ IProvider = (*TokenPool)(nil)
@@ -0,0 +1,154 @@
+package anticaptcha
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func waitForCalls(t *testing.T, stub *stubProvider, calls int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		stub.mu.Lock()
+		n := stub.calls
+		stub.mu.Unlock()
+
+		if n >= calls {
+			// give the refills time to store their tokens
+			time.Sleep(10 * time.Millisecond)
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("provider was not called %v times", calls)
+}
+
+func TestTokenPool(t *testing.T) {
+	stub := &stubProvider{name: "stub", solution: "token"}
+	pool := NewTokenPool(stub, TokenPoolOptions{Size: 2})
+	defer pool.Close()
+
+	ctx := context.Background()
+	settings := NewSettings()
+	payload := &RecaptchaV2Payload{EndpointUrl: "https://example.com", EndpointKey: "key"}
+
+	if _, err := pool.SolveRecaptchaV2(ctx, settings, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	// the token solved for the request counts towards the pool, so only one refill
+	waitForCalls(t, stub, 2)
+
+	resp, err := pool.SolveRecaptchaV2(ctx, settings, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, _ := resp.Solution(); solution != "token" {
+		t.Fatalf("unexpected solution %q", solution)
+	}
+
+	// a different site key has its own pool
+	if _, err := pool.SolveRecaptchaV2(ctx, settings, &RecaptchaV2Payload{EndpointUrl: "https://example.com", EndpointKey: "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := pool.Stats(); stats.Hits != 1 || stats.Misses != 2 || stats.Expired != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTokenPoolReplacesExpired(t *testing.T) {
+	stub := &stubProvider{name: "stub", solution: "token"}
+	pool := NewTokenPool(stub, TokenPoolOptions{Size: 1, TTL: 20 * time.Millisecond})
+	defer pool.Close()
+
+	payload := &HCaptchaPayload{EndpointUrl: "https://example.com", EndpointKey: "key"}
+	if _, err := pool.SolveHCaptcha(context.Background(), NewSettings(), payload); err != nil {
+		t.Fatal(err)
+	}
+
+	// the request, the refill and the replacement of the expired token
+	waitForCalls(t, stub, 3)
+}
+
+func TestTokenPoolIdle(t *testing.T) {
+	stub := &stubProvider{name: "stub", solution: "token"}
+	pool := NewTokenPool(stub, TokenPoolOptions{Size: 1, TTL: 20 * time.Millisecond, IdleTimeout: 10 * time.Millisecond})
+	defer pool.Close()
+
+	payload := &HCaptchaPayload{EndpointUrl: "https://example.com", EndpointKey: "key"}
+	if _, err := pool.SolveHCaptcha(context.Background(), NewSettings(), payload); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForCalls(t, stub, 2)
+	time.Sleep(50 * time.Millisecond)
+
+	// the token expired after nobody asked for a while, so it is not replaced
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if stub.calls != 2 {
+		t.Fatalf("expected no refill after idling, provider was called %v times", stub.calls)
+	}
+
+	if stats := pool.Stats(); stats.Expired != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if len(pool.queues) != 0 {
+		t.Errorf("expected the idle queue to be dropped, %d are left", len(pool.queues))
+	}
+}
+
+func TestTokenPoolBudgetLabel(t *testing.T) {
+	stub := &stubProvider{name: "stub", solution: "token"}
+	budget := NewBudgetProvider(stub, BudgetConfig{
+		Budgets: []Budget{{Name: "checkout", Limit: 10, Label: "checkout"}},
+		Prices:  map[CaptchaType]float64{CaptchaTypeRecaptchaV2: 1},
+	})
+	pool := NewTokenPool(budget, TokenPoolOptions{Size: 3})
+	defer pool.Close()
+
+	ctx, cancel := context.WithCancel(WithBudgetLabel(context.Background(), "checkout"))
+	payload := &RecaptchaV2Payload{EndpointUrl: "https://example.com", EndpointKey: "key"}
+	if _, err := pool.SolveRecaptchaV2(ctx, NewSettings(), payload); err != nil {
+		t.Fatal(err)
+	}
+
+	// the refills outlive the request but keep its label
+	cancel()
+	waitForCalls(t, stub, 3)
+
+	if usage := budget.Usage(); len(usage) != 1 || usage[0].Spent != 3 {
+		t.Errorf("expected the refills to count against the label, got %+v", usage)
+	}
+}
+
+func TestTokenPoolPrune(t *testing.T) {
+	pool := NewTokenPool(&stubProvider{name: "stub"}, TokenPoolOptions{})
+	defer pool.Close()
+
+	now := time.Now()
+	q := &tokenQueue{tokens: []pooledToken{
+		{resp: &CaptchaResponse{solution: "fresh"}, expiresAt: now.Add(time.Minute)},
+		{resp: &CaptchaResponse{solution: "stale"}, expiresAt: now.Add(-time.Second)},
+		{resp: &CaptchaResponse{solution: "fresher"}, expiresAt: now.Add(2 * time.Minute)},
+	}}
+
+	// providers report when tokens were solved, so a token behind the head can expire first
+	pool.prune(q, now)
+	if len(q.tokens) != 2 || q.tokens[0].resp.(*CaptchaResponse).solution != "fresh" || q.tokens[1].resp.(*CaptchaResponse).solution != "fresher" {
+		t.Errorf("unexpected tokens after pruning %+v", q.tokens)
+	}
+
+	if stats := pool.Stats(); stats.Expired != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
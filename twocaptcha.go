@@ -3,24 +3,23 @@ package anticaptcha
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
-
-	"github.com/packman80/anticaptcha/internal"
 )
 
 type TwoCaptcha struct {
+	name    string
 	baseUrl string
 	apiKey  string
 }
 
 func NewTwoCaptcha(apiKey string) *TwoCaptcha {
 	return &TwoCaptcha{
+		name:    "2captcha",
 		apiKey:  apiKey,
 		baseUrl: "https://2captcha.com",
 	}
@@ -30,66 +29,69 @@ func NewTwoCaptcha(apiKey string) *TwoCaptcha {
 // have the exact same API as AntiCaptcha, thus allowing you to use these providers with ease.
 func NewCustomTwoCaptcha(baseUrl, apiKey string) *TwoCaptcha {
 	return &TwoCaptcha{
+		name:    nameFromBaseUrl(baseUrl, "2captcha"),
 		baseUrl: baseUrl,
 		apiKey:  apiKey,
 	}
 }
 
+// Name returns the name of the provider as used in errors, e.g. "2captcha" or the host of a custom baseUrl.
+func (t *TwoCaptcha) Name() string {
+	return t.name
+}
+
 func (t *TwoCaptcha) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
-	task := &url.Values{}
-	task.Set("method", "base64")
-	task.Set("body", payload.Base64String)
+	return t.solve(ctx, settings, payload)
+}
 
-	if payload.InstructionsForSolver != "" {
-		task.Set("textinstructions", payload.InstructionsForSolver)
-	}
+func (t *TwoCaptcha) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
+	return t.solve(ctx, settings, payload)
+}
 
-	if payload.CaseSensitive {
-		task.Set("regsense", "1")
-	}
+func (t *TwoCaptcha) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
+	return t.solve(ctx, settings, payload)
+}
 
-	result, err := t.solveTask(ctx, settings, task)
-	if err != nil {
-		return nil, err
-	}
+func (t *TwoCaptcha) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
+	return t.solve(ctx, settings, payload)
+}
 
-	return result, nil
+func (t *TwoCaptcha) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
+	return t.solve(ctx, settings, payload)
 }
 
-func (t *TwoCaptcha) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
-	task := &url.Values{}
-	task.Set("method", "userrecaptcha")
-	task.Set("googlekey", payload.EndpointKey)
-	task.Set("pageurl", payload.EndpointUrl)
+func (t *TwoCaptcha) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
+	return t.solve(ctx, settings, payload)
+}
 
-	if payload.IsInvisibleCaptcha {
-		task.Set("invisible", "1")
-	}
+func (t *TwoCaptcha) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+	return t.solve(ctx, settings, payload)
+}
 
-	result, err := t.solveTask(ctx, settings, task)
+// CreateTask submits the payload without waiting for the solution, see CaptchaSolver.Submit
+func (t *TwoCaptcha) CreateTask(ctx context.Context, settings *Settings, payload any) (*TaskHandle, error) {
+	captchaType, task, err := t.buildTask(payload)
 	if err != nil {
+		settings.observeRefused(t.name, captchaType, err)
 		return nil, err
 	}
 
-	return result, nil
+	return t.submit(ctx, settings, captchaType, task)
 }
 
-func (t *TwoCaptcha) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
-	task := &url.Values{}
-	task.Set("method", "userrecaptcha")
-	task.Set("version", "v3")
-	task.Set("googlekey", payload.EndpointKey)
-	task.Set("pageurl", payload.EndpointUrl)
-
-	if payload.Action != "" {
-		task.Set("action", payload.Action)
-	}
+// GetResult fetches the result of the task once, see CaptchaSolver.Poll
+func (t *TwoCaptcha) GetResult(ctx context.Context, settings *Settings, handle *TaskHandle) (ICaptchaResponse, error) {
+	return fetchResult(ctx, settings, t, handle, t.getResult)
+}
 
-	if payload.IsEnterprise {
-		task.Set("enterprise", "1")
+func (t *TwoCaptcha) solve(ctx context.Context, settings *Settings, payload any) (ICaptchaResponse, error) {
+	captchaType, task, err := t.buildTask(payload)
+	if err != nil {
+		settings.observeRefused(t.name, captchaType, err)
+		return nil, err
 	}
 
-	result, err := t.solveTask(ctx, settings, task)
+	result, err := t.solveTask(ctx, settings, captchaType, task)
 	if err != nil {
 		return nil, err
 	}
@@ -97,54 +99,84 @@ func (t *TwoCaptcha) SolveRecaptchaV3(ctx context.Context, settings *Settings, p
 	return result, nil
 }
 
-func (t *TwoCaptcha) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
-	task := &url.Values{}
-	task.Set("method", "hcaptcha")
-	task.Set("sitekey", payload.EndpointKey)
-	task.Set("pageurl", payload.EndpointUrl)
-
-	result, err := t.solveTask(ctx, settings, task)
+// buildTask converts a payload into the in.php parameters of 2Captcha.
+func (t *TwoCaptcha) buildTask(payload any) (CaptchaType, *url.Values, error) {
+	captchaType, err := captchaTypeOf(t.name, payload)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
-	return result, nil
-}
-
-func (t *TwoCaptcha) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
 	task := &url.Values{}
-	task.Set("method", "turnstile")
-	task.Set("sitekey", payload.EndpointKey)
-	task.Set("pageurl", payload.EndpointUrl)
+	switch payload := payload.(type) {
+	case *ImageCaptchaPayload:
+		task.Set("method", "base64")
+		task.Set("body", payload.Base64String)
 
-	result, err := t.solveTask(ctx, settings, task)
-	if err != nil {
-		return nil, err
-	}
+		if payload.InstructionsForSolver != "" {
+			task.Set("textinstructions", payload.InstructionsForSolver)
+		}
 
-	return result, nil
-}
+		if payload.CaseSensitive {
+			task.Set("regsense", "1")
+		}
+	case *RecaptchaV2Payload:
+		task.Set("method", "userrecaptcha")
+		task.Set("googlekey", payload.EndpointKey)
+		task.Set("pageurl", payload.EndpointUrl)
 
-func (t *TwoCaptcha) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
-	task := &url.Values{}
-	task.Set("method", "base64")
-	task.Set("coordinatescaptcha", "1")
-	task.Set("body", payload.Body)
-	task.Set("imginstructions", payload.ImageInstructions)
+		if payload.IsInvisibleCaptcha {
+			task.Set("invisible", "1")
+		}
+
+		err = setTwoCaptchaProxy(task, payload.Proxy)
+	case *RecaptchaV3Payload:
+		task.Set("method", "userrecaptcha")
+		task.Set("version", "v3")
+		task.Set("googlekey", payload.EndpointKey)
+		task.Set("pageurl", payload.EndpointUrl)
+
+		if payload.Action != "" {
+			task.Set("action", payload.Action)
+		}
+
+		if payload.IsEnterprise {
+			task.Set("enterprise", "1")
+		}
+
+		err = setTwoCaptchaProxy(task, payload.Proxy)
+	case *HCaptchaPayload:
+		task.Set("method", "hcaptcha")
+		task.Set("sitekey", payload.EndpointKey)
+		task.Set("pageurl", payload.EndpointUrl)
+
+		err = setTwoCaptchaProxy(task, payload.Proxy)
+	case *TurnstilePayload:
+		task.Set("method", "turnstile")
+		task.Set("sitekey", payload.EndpointKey)
+		task.Set("pageurl", payload.EndpointUrl)
+
+		err = setTwoCaptchaProxy(task, payload.Proxy)
+	case *CoordinatesPayload:
+		task.Set("method", "base64")
+		task.Set("coordinatescaptcha", "1")
+		task.Set("body", payload.Body)
+		task.Set("imginstructions", payload.ImageInstructions)
+	case *CustomPayload:
+		return captchaType, task, t.customParams(task, payload)
+	}
 
-	result, err := t.solveTask(ctx, settings, task)
 	if err != nil {
-		return nil, err
+		return captchaType, nil, proxyError(t.name, captchaType, err)
 	}
 
-	return result, nil
+	return captchaType, task, nil
 }
 
-func (t *TwoCaptcha) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
+func (t *TwoCaptcha) customParams(task *url.Values, payload *CustomPayload) error {
 	if len(payload.Params) == 0 {
-		return nil, fmt.Errorf("Params for custom captcha are absent")
+		return &ProviderError{Provider: t.name, CaptchaType: CaptchaTypeCustom, Description: "Params for custom captcha are absent", Err: ErrBadPayload}
 	}
-	task := &url.Values{}
+
 	for k, v := range payload.Params {
 		switch val := v.(type) {
 		case string:
@@ -158,70 +190,75 @@ func (t *TwoCaptcha) SolveCustom(ctx context.Context, settings *Settings, payloa
 		case float32:
 			task.Set(k, strconv.FormatFloat(float64(val), 'f', -1, 32))
 		default:
-			return nil, fmt.Errorf("Unexpected type %T for key %s", v, k)
+			return &ProviderError{
+				Provider:    t.name,
+				CaptchaType: CaptchaTypeCustom,
+				Description: fmt.Sprintf("Unexpected type %T for key %s", v, k),
+				Err:         ErrBadPayload,
+			}
 		}
 	}
 
-	result, err := t.solveTask(ctx, settings, task)
-	if err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	return nil
 }
 
-func (t *TwoCaptcha) Report(ctx context.Context, action, taskId string, settings *Settings) error {
-	body := url.Values{}
-	body.Set("key", t.apiKey)
-	body.Set("action", action)
-	body.Set("id", taskId)
+// setTwoCaptchaProxy adds the proxy as login:password@address:port, it does nothing when proxy is nil.
+func setTwoCaptchaProxy(task *url.Values, proxy *Proxy) error {
+	if proxy == nil {
+		return nil
+	}
 
-	fullURL := fmt.Sprintf("%s/res.php?%s", t.baseUrl, body.Encode())
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
-	if err != nil {
+	if err := proxy.validate(); err != nil {
 		return err
 	}
 
-	resp, err := settings.client.Do(req)
-	if err != nil {
-		return err
+	address := net.JoinHostPort(proxy.Address, strconv.Itoa(proxy.Port))
+	if proxy.Login != "" {
+		address = proxy.Login + ":" + proxy.Password + "@" + address
 	}
 
-	io.Copy(io.Discard, resp.Body)
-	resp.Body.Close()
+	task.Set("proxy", address)
+	task.Set("proxytype", strings.ToUpper(string(proxy.proxyType())))
 
 	return nil
 }
 
-func (t *TwoCaptcha) solveTask(ctx context.Context, settings *Settings, task *url.Values) (*CaptchaResponse, error) {
-	taskId, err := t.createTask(ctx, settings, task)
-	if err != nil {
-		return nil, err
-	}
+// Report sends action (reportgood or reportbad) for the task to the provider
+func (t *TwoCaptcha) Report(ctx context.Context, action, taskId string, settings *Settings) error {
+	return resReport(ctx, settings, t.name, t.baseUrl, t.apiKey, action, "", taskId)
+}
+
+func (t *TwoCaptcha) ReportCorrect(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) error {
+	return resReport(ctx, settings, t.name, t.baseUrl, t.apiKey, "reportgood", captchaType, taskId)
+}
+
+func (t *TwoCaptcha) ReportIncorrect(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) error {
+	return resReport(ctx, settings, t.name, t.baseUrl, t.apiKey, "reportbad", captchaType, taskId)
+}
 
-	if err := internal.SleepWithContext(ctx, settings.initialWaitTime); err != nil {
+func (t *TwoCaptcha) solveTask(ctx context.Context, settings *Settings, captchaType CaptchaType, task *url.Values) (*CaptchaResponse, error) {
+	handle, err := t.submit(ctx, settings, captchaType, task)
+	if err != nil {
 		return nil, err
 	}
 
-	for i := 0; i < settings.maxRetries; i++ {
-		answer, err := t.getResult(ctx, settings, taskId)
-		if err != nil {
-			return nil, err
-		}
-
-		if answer != "" {
-			return &CaptchaResponse{solution: answer, taskId: taskId}, nil
-		}
+	return pollResult(ctx, settings, t, handle, t.getResult)
+}
 
-		if err := internal.SleepWithContext(ctx, settings.pollInterval); err != nil {
-			return nil, err
-		}
+// submit creates the task, asking for a callback when the settings have a callback receiver.
+func (t *TwoCaptcha) submit(ctx context.Context, settings *Settings, captchaType CaptchaType, task *url.Values) (*TaskHandle, error) {
+	handle, err := submitTask(settings, t.name, captchaType, func() (string, error) {
+		return t.createTask(ctx, settings, captchaType, task)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("max tries exceeded")
+	handle.callback = settings.callback != nil
+	return handle, nil
 }
 
-func (t *TwoCaptcha) createTask(ctx context.Context, settings *Settings, payload *url.Values) (string, error) {
+func (t *TwoCaptcha) createTask(ctx context.Context, settings *Settings, captchaType CaptchaType, payload *url.Values) (string, error) {
 	type response struct {
 		Status    int    `json:"status"`
 		Request   string `json:"request"`
@@ -230,25 +267,15 @@ func (t *TwoCaptcha) createTask(ctx context.Context, settings *Settings, payload
 
 	payload.Set("key", t.apiKey)
 	payload.Set("json", "1")
-
-	fullURL := fmt.Sprintf("%v/in.php", t.baseUrl)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, strings.NewReader(payload.Encode()))
-	if err != nil {
-		return "", nil
+	if callbackURL := settings.callbackURL(t.name); callbackURL != "" {
+		payload.Set("pingback", callbackURL)
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := settings.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+	fullURL := fmt.Sprintf("%v/in.php", t.baseUrl)
 
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := doRequest(ctx, settings, t.name, requestCreate, http.MethodPost, fullURL, "application/x-www-form-urlencoded", []byte(payload.Encode()))
 	if err != nil {
-		return "", err
+		return "", taskError(err, captchaType, "")
 	}
 
 	var jsonResp response
@@ -257,17 +284,18 @@ func (t *TwoCaptcha) createTask(ctx context.Context, settings *Settings, payload
 	}
 
 	if jsonResp.Status == 0 {
-		return "", fmt.Errorf("%v: %v", jsonResp.Request, jsonResp.ErrorText)
+		return "", newProviderError(twoCaptchaErrors, t.name, captchaType, "", jsonResp.Request, jsonResp.ErrorText)
 	}
 
 	return jsonResp.Request, nil
 }
 
-func (t *TwoCaptcha) getResult(ctx context.Context, settings *Settings, taskId string) (string, error) {
+func (t *TwoCaptcha) getResult(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) (*CaptchaResponse, error) {
 	type response struct {
-		Status    int    `json:"status"`
-		Request   string `json:"request"`
-		ErrorText string `json:"error_text"`
+		Status    int             `json:"status"`
+		Request   json.RawMessage `json:"request"`
+		ErrorText string          `json:"error_text"`
+		UserAgent string          `json:"useragent"`
 	}
 
 	body := &url.Values{}
@@ -278,37 +306,45 @@ func (t *TwoCaptcha) getResult(ctx context.Context, settings *Settings, taskId s
 
 	fullURL := fmt.Sprintf("%v/res.php?%v", t.baseUrl, body.Encode())
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	respBody, err := doRequest(ctx, settings, t.name, requestPoll, http.MethodGet, fullURL, "", nil)
 	if err != nil {
-		return "", err
-	}
-
-	resp, err := settings.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+		return nil, taskError(err, captchaType, taskId)
 	}
 
 	var jsonResp response
 	if err := json.Unmarshal(respBody, &jsonResp); err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// request is a string for most captchas, coordinates captchas answer with an array of points
+	answer := string(jsonResp.Request)
+	var text string
+	if err := json.Unmarshal(jsonResp.Request, &text); err == nil {
+		answer = text
 	}
 
 	if jsonResp.Status == 0 {
-		fmt.Println(jsonResp.Request)
-		if jsonResp.ErrorText == "" {
-			return "", nil
+		if answer == "CAPCHA_NOT_READY" {
+			return nil, nil
 		}
 
-		return "", fmt.Errorf("%v: %v", jsonResp.Request, jsonResp.ErrorText)
+		return nil, newProviderError(twoCaptchaErrors, t.name, captchaType, taskId, answer, jsonResp.ErrorText)
 	}
 
-	return jsonResp.Request, nil
+	return &CaptchaResponse{
+		solution:  answer,
+		userAgent: jsonResp.UserAgent,
+		raw:       jsonResp.Request,
+	}, nil
 }
 
-var _ IProvider = (*TwoCaptcha)(nil)
+func (t *TwoCaptcha) Balance(ctx context.Context, settings *Settings) (float64, error) {
+	return resBalance(ctx, settings, t.name, t.baseUrl, t.apiKey)
+}
+
+var (
+	_ IProvider        = (*TwoCaptcha)(nil)
+	_ IBalanceProvider = (*TwoCaptcha)(nil)
+	_ IReporter        = (*TwoCaptcha)(nil)
+	_ IAsyncProvider   = (*TwoCaptcha)(nil)
+)
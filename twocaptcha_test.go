@@ -2,8 +2,11 @@ package anticaptcha
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
+
+	"github.com/packman80/anticaptcha/anticaptchatest"
 )
 
 func TestNewTwoCaptcha(t *testing.T) {
@@ -21,3 +24,75 @@ func TestNewTwoCaptcha(t *testing.T) {
 
 	t.Log(resp.Solution())
 }
+
+func TestTwoCaptchaOffline(t *testing.T) {
+	srv := anticaptchatest.NewTwoCaptchaServer()
+	defer srv.Close()
+	srv.SetKey("key")
+	srv.Enqueue(anticaptchatest.Task{NotReady: 3, Solution: "token"})
+
+	cs := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "key"), offlineOptions...)
+	resp, err := cs.SolveHCaptcha(context.Background(), &HCaptchaPayload{EndpointUrl: "https://example.com", EndpointKey: "site"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, taskId := resp.Solution(); solution != "token" || taskId != "1" || srv.Polls("1") != 4 {
+		t.Errorf("unexpected solution %q for task %q after %d polls", solution, taskId, srv.Polls("1"))
+	}
+
+	form := srv.RequestsTo("/in.php")[0].Form
+	if form.Get("method") != "hcaptcha" || form.Get("sitekey") != "site" || form.Get("pageurl") != "https://example.com" || form.Get("json") != "1" {
+		t.Errorf("unexpected task %v", form)
+	}
+
+	if err := cs.ReportCorrect(context.Background(), resp); err != nil {
+		t.Errorf("report failed: %v", err)
+	}
+
+	reports := srv.RequestsTo("/res.php")
+	if last := reports[len(reports)-1]; last.Query.Get("action") != "reportgood" || last.Query.Get("id") != "1" {
+		t.Errorf("unexpected report %v", last.Query)
+	}
+}
+
+func TestTwoCaptchaOfflineErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		task anticaptchatest.Task
+		err  error
+	}{
+		{"zero balance", anticaptchatest.Task{CreateError: "ERROR_ZERO_BALANCE"}, ErrZeroBalance},
+		{"no slot", anticaptchatest.Task{CreateError: "ERROR_NO_SLOT_AVAILABLE"}, ErrNoSlot},
+		{"unsolvable", anticaptchatest.Task{NotReady: 2, Error: "ERROR_CAPTCHA_UNSOLVABLE"}, ErrUnsolvable},
+	}
+
+	for _, test := range tests {
+		srv := anticaptchatest.NewTwoCaptchaServer()
+		srv.Enqueue(test.task)
+
+		cs := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "key"), offlineOptions...)
+		_, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+		if !errors.Is(err, test.err) {
+			t.Errorf("%v: expected %v, got %v", test.name, test.err, err)
+		}
+
+		srv.Close()
+	}
+}
+
+func TestTwoCaptchaOfflineTimeout(t *testing.T) {
+	srv := anticaptchatest.NewTwoCaptchaServer()
+	defer srv.Close()
+	srv.Enqueue(anticaptchatest.Task{NotReady: 100})
+
+	cs := NewCaptchaSolver(NewCustomTwoCaptcha(srv.URL, "key"), append(offlineOptions, WithMaxRetries(3))...)
+	_, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{Base64String: "aW1hZ2U="})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	if srv.Polls("1") != 3 {
+		t.Errorf("expected polling to stop after 3 polls, got %d", srv.Polls("1"))
+	}
+}
@@ -1,19 +1,16 @@
 package anticaptcha
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
+	"maps"
 	"net/http"
 	"net/url"
-
-	"github.com/packman80/anticaptcha/internal"
 )
 
 type WhiteCaptcha struct {
+	name    string
 	baseUrl string
 	apiKey  string
 }
@@ -26,6 +23,7 @@ type Response struct {
 
 func NewWhiteCaptcha(apiKey string) *WhiteCaptcha {
 	return &WhiteCaptcha{
+		name:    "whitecaptcha",
 		apiKey:  apiKey,
 		baseUrl: "http://api.white-captcha.com",
 	}
@@ -35,105 +33,122 @@ func NewWhiteCaptcha(apiKey string) *WhiteCaptcha {
 // have the exact same API as WhiteCaptcha, thus allowing you to use these providers with ease.
 func NewCustomWhiteCaptcha(baseUrl, apiKey string) *WhiteCaptcha {
 	return &WhiteCaptcha{
+		name:    nameFromBaseUrl(baseUrl, "whitecaptcha"),
 		baseUrl: baseUrl,
 		apiKey:  apiKey,
 	}
 }
 
-func (a *WhiteCaptcha) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
-	task := map[string]any{
-		"type": "ImageToTextTask",
-		"body": payload.Base64String,
-		"case": payload.CaseSensitive,
-	}
+// Name returns the name of the provider as used in errors, e.g. "whitecaptcha" or the host of a custom baseUrl.
+func (a *WhiteCaptcha) Name() string {
+	return a.name
+}
 
-	result, err := a.solveTask(ctx, settings, task)
-	if err != nil {
-		return nil, err
-	}
-	return result, nil
+func (a *WhiteCaptcha) SolveImageCaptcha(ctx context.Context, settings *Settings, payload *ImageCaptchaPayload) (ICaptchaResponse, error) {
+	return a.solve(ctx, settings, payload)
 }
 
 func (a *WhiteCaptcha) SolveRecaptchaV2(ctx context.Context, settings *Settings, payload *RecaptchaV2Payload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeRecaptchaV2)
 }
 
 func (a *WhiteCaptcha) SolveRecaptchaV3(ctx context.Context, settings *Settings, payload *RecaptchaV3Payload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeRecaptchaV3)
 }
 
 func (a *WhiteCaptcha) SolveHCaptcha(ctx context.Context, settings *Settings, payload *HCaptchaPayload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeHCaptcha)
 }
 
 func (a *WhiteCaptcha) SolveTurnstile(ctx context.Context, settings *Settings, payload *TurnstilePayload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeTurnstile)
 }
 
 func (a *WhiteCaptcha) SolveCoordinates(ctx context.Context, settings *Settings, payload *CoordinatesPayload) (ICaptchaResponse, error) {
-	return nil, fmt.Errorf("Captcha doesn't support")
+	return nil, refuseUnsupported(settings, a.name, CaptchaTypeCoordinates)
 }
 
 func (a *WhiteCaptcha) SolveCustom(ctx context.Context, settings *Settings, payload *CustomPayload) (ICaptchaResponse, error) {
-	result, err := a.solveTask(ctx, settings, payload.Params)
+	return a.solve(ctx, settings, payload)
+}
+
+// CreateTask submits the payload without waiting for the solution, see CaptchaSolver.Submit
+func (a *WhiteCaptcha) CreateTask(ctx context.Context, settings *Settings, payload any) (*TaskHandle, error) {
+	captchaType, task, err := a.buildTask(payload)
 	if err != nil {
+		settings.observeRefused(a.name, captchaType, err)
 		return nil, err
 	}
 
-	return result, nil
+	return submitTask(settings, a.name, captchaType, func() (string, error) {
+		return a.createTask(ctx, settings, captchaType, task)
+	})
+}
+
+// GetResult fetches the result of the task once, see CaptchaSolver.Poll
+func (a *WhiteCaptcha) GetResult(ctx context.Context, settings *Settings, handle *TaskHandle) (ICaptchaResponse, error) {
+	return fetchResult(ctx, settings, a, handle, a.getResult)
 }
 
-func (a *WhiteCaptcha) solveTask(ctx context.Context, settings *Settings, task map[string]any) (*CaptchaResponse, error) {
-	taskId, err := a.createTask(ctx, settings, task)
+func (a *WhiteCaptcha) solve(ctx context.Context, settings *Settings, payload any) (ICaptchaResponse, error) {
+	captchaType, task, err := a.buildTask(payload)
 	if err != nil {
+		settings.observeRefused(a.name, captchaType, err)
 		return nil, err
 	}
 
-	if err := internal.SleepWithContext(ctx, settings.initialWaitTime); err != nil {
+	result, err := a.solveTask(ctx, settings, captchaType, task)
+	if err != nil {
 		return nil, err
 	}
 
-	for i := 0; i < settings.maxRetries; i++ {
-		answer, err := a.getResult(ctx, settings, taskId)
-		if err != nil {
-			return nil, err
-		}
+	return result, nil
+}
 
-		if answer != "" {
-			return &CaptchaResponse{solution: answer, taskId: taskId}, nil
-		}
+// buildTask converts a payload into a WhiteCaptcha task, only image and custom captchas are supported.
+func (a *WhiteCaptcha) buildTask(payload any) (CaptchaType, map[string]any, error) {
+	captchaType, err := captchaTypeOf(a.name, payload)
+	if err != nil {
+		return "", nil, err
+	}
 
-		if err := internal.SleepWithContext(ctx, settings.pollInterval); err != nil {
-			return nil, err
-		}
+	switch payload := payload.(type) {
+	case *ImageCaptchaPayload:
+		return captchaType, map[string]any{
+			"type": "ImageToTextTask",
+			"body": payload.Base64String,
+			"case": payload.CaseSensitive,
+		}, nil
+	case *CustomPayload:
+		// createTask adds the key to the task, which must not end up in the params of the caller
+		return captchaType, maps.Clone(payload.Params), nil
 	}
 
-	return nil, errors.New("max tries exceeded")
+	return captchaType, nil, unsupportedError(a.name, captchaType)
 }
 
-func (a *WhiteCaptcha) createTask(ctx context.Context, settings *Settings, task map[string]any) (string, error) {
-	task["key"] = a.apiKey
-	task["json"] = 1
-	jsonValue, err := json.Marshal(task)
+func (a *WhiteCaptcha) solveTask(ctx context.Context, settings *Settings, captchaType CaptchaType, task map[string]any) (*CaptchaResponse, error) {
+	handle, err := submitTask(settings, a.name, captchaType, func() (string, error) {
+		return a.createTask(ctx, settings, captchaType, task)
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseUrl+"/in.php", bytes.NewBuffer(jsonValue))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("content-type", "application/json")
+	return pollResult(ctx, settings, a, handle, a.getResult)
+}
 
-	resp, err := settings.client.Do(req)
+func (a *WhiteCaptcha) createTask(ctx context.Context, settings *Settings, captchaType CaptchaType, task map[string]any) (string, error) {
+	task["key"] = a.apiKey
+	task["json"] = 1
+	jsonValue, err := json.Marshal(task)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := doRequest(ctx, settings, a.name, requestCreate, http.MethodPost, a.baseUrl+"/in.php", "application/json", jsonValue)
 	if err != nil {
-		return "", err
+		return "", taskError(err, captchaType, "")
 	}
 
 	var responseAsJSON Response
@@ -142,13 +157,13 @@ func (a *WhiteCaptcha) createTask(ctx context.Context, settings *Settings, task
 	}
 
 	if responseAsJSON.Status == 0 {
-		return "", fmt.Errorf("%v", responseAsJSON.Request)
+		return "", newProviderError(twoCaptchaErrors, a.name, captchaType, "", responseAsJSON.Request, responseAsJSON.ErrorText)
 	}
 
 	return responseAsJSON.Request, nil
 }
 
-func (a *WhiteCaptcha) getResult(ctx context.Context, settings *Settings, taskId string) (string, error) {
+func (a *WhiteCaptcha) getResult(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) (*CaptchaResponse, error) {
 	body := &url.Values{}
 	body.Set("key", a.apiKey)
 	body.Set("json", "1")
@@ -156,58 +171,46 @@ func (a *WhiteCaptcha) getResult(ctx context.Context, settings *Settings, taskId
 
 	fullURL := fmt.Sprintf("%v/res.php?%v", a.baseUrl, body.Encode())
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	respBody, err := doRequest(ctx, settings, a.name, requestPoll, http.MethodGet, fullURL, "", nil)
 	if err != nil {
-		return "", err
-	}
-
-	resp, err := settings.client.Do(req)
-	if err != nil {
-		return "", nil
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+		return nil, taskError(err, captchaType, taskId)
 	}
 
 	var respJson Response
 	if err := json.Unmarshal(respBody, &respJson); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if respJson.Status == 0 {
 		if respJson.Request == "CAPCHA_NOT_READY" {
-			return "", nil
+			return nil, nil
 		}
 
-		return "", fmt.Errorf("%v", respJson.Request)
+		return nil, newProviderError(twoCaptchaErrors, a.name, captchaType, taskId, respJson.Request, respJson.ErrorText)
 	}
-	return respJson.Request, nil
+	return &CaptchaResponse{solution: respJson.Request}, nil
 }
 
-func (t *WhiteCaptcha) Report(ctx context.Context, action, taskId string, settings *Settings) error {
-	body := url.Values{}
-	body.Set("key", t.apiKey)
-	body.Set("action", action)
-	body.Set("id", taskId)
-
-	fullURL := fmt.Sprintf("%s/res.php?%s", t.baseUrl, body.Encode())
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
-	if err != nil {
-		return err
-	}
+// Report sends action (reportgood or reportbad) for the task to the provider
+func (a *WhiteCaptcha) Report(ctx context.Context, action, taskId string, settings *Settings) error {
+	return resReport(ctx, settings, a.name, a.baseUrl, a.apiKey, action, "", taskId)
+}
 
-	resp, err := settings.client.Do(req)
-	if err != nil {
-		return err
-	}
+func (a *WhiteCaptcha) ReportCorrect(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) error {
+	return resReport(ctx, settings, a.name, a.baseUrl, a.apiKey, "reportgood", captchaType, taskId)
+}
 
-	io.Copy(io.Discard, resp.Body)
-	resp.Body.Close()
+func (a *WhiteCaptcha) ReportIncorrect(ctx context.Context, settings *Settings, captchaType CaptchaType, taskId string) error {
+	return resReport(ctx, settings, a.name, a.baseUrl, a.apiKey, "reportbad", captchaType, taskId)
+}
 
-	return nil
+func (a *WhiteCaptcha) Balance(ctx context.Context, settings *Settings) (float64, error) {
+	return resBalance(ctx, settings, a.name, a.baseUrl, a.apiKey)
 }
 
-var _ IProvider = (*WhiteCaptcha)(nil)
+var (
+	_ IProvider        = (*WhiteCaptcha)(nil)
+	_ IBalanceProvider = (*WhiteCaptcha)(nil)
+	_ IReporter        = (*WhiteCaptcha)(nil)
+	_ IAsyncProvider   = (*WhiteCaptcha)(nil)
+)
@@ -0,0 +1,44 @@
+package anticaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/packman80/anticaptcha/anticaptchatest"
+)
+
+func TestWhiteCaptchaOffline(t *testing.T) {
+	srv := anticaptchatest.NewWhiteCaptchaServer()
+	defer srv.Close()
+	srv.SetKey("key")
+	srv.Enqueue(anticaptchatest.Task{NotReady: 1, Solution: "answer"}, anticaptchatest.Task{MalformedCreate: true})
+
+	cs := NewCaptchaSolver(NewCustomWhiteCaptcha(srv.URL, "key"), offlineOptions...)
+	resp, err := cs.SolveImageCaptcha(context.Background(), &ImageCaptchaPayload{Base64String: "aW1hZ2U=", CaseSensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solution, taskId := resp.Solution(); solution != "answer" || taskId != "1" {
+		t.Errorf("unexpected solution %q for task %q", solution, taskId)
+	}
+
+	task := srv.RequestsTo("/in.php")[0]
+	if task.JSON["type"] != "ImageToTextTask" || task.JSON["body"] != "aW1hZ2U=" || task.JSON["case"] != true || task.Param("key") != "key" {
+		t.Errorf("unexpected task %v", task.JSON)
+	}
+
+	params := map[string]any{"method": "custom"}
+	if _, err := cs.SolveCustom(context.Background(), &CustomPayload{Params: params}); err == nil {
+		t.Error("expected malformed JSON to fail the task")
+	}
+
+	if _, ok := params["key"]; ok {
+		t.Error("the api key was added to the params of the caller")
+	}
+
+	if _, err := cs.SolveRecaptchaV2(context.Background(), &RecaptchaV2Payload{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}